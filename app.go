@@ -4,35 +4,207 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/aquarelle-tech/darkmatter/attestation"
 	"github.com/aquarelle-tech/darkmatter/crawlers"
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/identity"
 	"github.com/aquarelle-tech/darkmatter/mapreduce"
 	"github.com/aquarelle-tech/darkmatter/service"
 	"github.com/aquarelle-tech/darkmatter/types"
 )
 
-// List of available crawlers
-var directory = []types.PriceEvidenceCrawler{
-	crawlers.NewBinanceCrawler(),
-	crawlers.NewLiquidCrawler(),
-	crawlers.NewBitfinexCrawler(),
+// Storage backends for the chains
+const (
+	BadgerStorage = "badger"
+	MemoryStorage = "memory" // Nothing is written to disk. For tests and demo nodes
+	SQLiteStorage = "sqlite" // To query the blocks and the evidence with SQL
+)
+
+var publishedPrices = make(chan mapreduce.Publication, mapreduce.DEFAULT_PUBLICATION_BUFFER)
+
+// Read the list of markets to track, like "BTC/USD,ETH/USD@1m". Each market can have its own interval between rounds
+func parsePipelines(text string) ([]mapreduce.PipelineConfig, error) {
+	var configs []mapreduce.PipelineConfig
+
+	for _, item := range strings.Split(text, ",") {
+		var config mapreduce.PipelineConfig
+		var err error
+
+		parts := strings.SplitN(strings.TrimSpace(item), "@", 2)
+		if len(parts) == 2 {
+			if config.Interval, err = time.ParseDuration(parts[1]); err != nil {
+				return nil, err
+			}
+		}
+		if config.Market, err = types.ParseMarket(parts[0]); err != nil {
+			return nil, err
+		}
+		// List of available crawlers for the market
+		config.Directory = crawlers.NewCrawlers(config.Market)
+
+		configs = append(configs, config)
+	}
+
+	return configs, nil
 }
 
-var publishedPrices = make(chan types.FullSignedBlock)
+// Returns the options to create the chains with a storage backend
+func storageOptions(storage string) ([]database.Option, error) {
+	switch storage {
+	case BadgerStorage:
+		return nil, nil
+	case MemoryStorage:
+		return []database.Option{database.WithMemoryStore()}, nil
+	case SQLiteStorage:
+		return []database.Option{database.WithSQLiteStore()}, nil
+	}
+
+	return nil, fmt.Errorf("unknown storage %q", storage)
+}
 
 func main() {
 
-	quotedCurrency := "USD"
+	markets := flag.String("markets", "BTC/USD", "Markets to track, as BASE/QUOTE separated by commas. The interval between rounds can be set with @, like ETH/USD@1m")
+	maxStaleness := flag.Duration("max-staleness", 0, "Maximum distance between the requested time and the block used to answer a price query. 0 means no limit")
+	keystoreDir := flag.String("keystore", identity.DefaultKeystoreDir, "Directory with the encrypted keys of the node. The public key of the active key is the address of the node")
+	passphraseFile := flag.String("passphrase-file", "", "File with the passphrase of the keystore. By default it is read from "+PassphraseEnv)
+	keyGenerate := flag.Bool("key-generate", false, "Create a new key in the keystore, print its address and exit")
+	keyImport := flag.String("key-import", "", "Import an unencrypted key file (the seed in hexadecimal) to the keystore and exit")
+	keyExport := flag.String("key-export", "", "Write the unencrypted active key to the file and exit")
+	keyHistory := flag.Bool("key-history", false, "Print the keys that signed the stored chains of the markets and exit")
+	attestations := flag.Bool("attestations", false, "Sign EIP-712 attestations of the prices with the secp256k1 key of the keystore, created if it doesn´t exist")
+	attestationChainID := flag.Uint64("attestation-chain-id", attestation.DefaultDomain.ChainID, "Chain id of the EIP-712 domain of the attestations")
+	attestationContract := flag.String("attestation-contract", attestation.DefaultDomain.VerifyingContract, "Address of the contract which verifies the attestations, in their EIP-712 domain")
+	attestationKeyImport := flag.String("attestation-key-import", "", "Import a secp256k1 private key file (in hexadecimal) as the attestation key and exit")
+	attestationVectors := flag.Bool("attestation-vectors", false, "Print the test vectors of the attestations (docs/attestation-vectors.json) and exit")
+	rotateTo := flag.String("rotate-key", "", "Rotate the key of the stored chains of the markets to the key of the keystore with the address, and exit. The node must be stopped")
+	storage := flag.String("storage", BadgerStorage, "Storage of the chains: badger, sqlite or memory")
+	verify := flag.Bool("verify", false, "Verify the stored chains of the markets and exit. The node must be stopped")
+	exportDir := flag.String("export", "", "Export the stored chains of the markets to files in the directory and exit")
+	exportFormat := flag.String("format", NDJSONFormat, "Format of the exported chains: ndjson (full blocks) or csv (blocks and evidence)")
+	hashVectors := flag.Bool("hash-vectors", false, "Print the test vectors of the canonical hash scheme (docs/hash-vectors.json) and exit")
+	importDir := flag.String("import", "", "Import the chains of the markets from the ndjson files in the directory and exit")
+	flag.Parse()
+
+	pipelines, err := parsePipelines(*markets)
+	if err != nil {
+		log.Fatal(err)
+	}
+	options, err := storageOptions(*storage)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// The commands which only read the chains don´t write to their storage
+	readOnly := append(append([]database.Option{}, options...), database.WithReadOnly())
+
+	domain := attestation.DefaultDomain
+	domain.ChainID = *attestationChainID
+	domain.VerifyingContract = *attestationContract
+
+	// The keystore is only opened by the commands which need it
+	withKeystore := func(command func(keystore *identity.Keystore) bool) bool {
+		keystore, err := openKeystore(*keystoreDir, *passphraseFile)
+		if err != nil {
+			log.Println(err)
+			return false
+		}
+		return command(keystore)
+	}
+
+	// Commands over the keys and the stored chains
+	var commandOk bool
+	switch {
+	case *keyGenerate:
+		commandOk = withKeystore(generateKey)
+	case *keyImport != "":
+		commandOk = withKeystore(func(keystore *identity.Keystore) bool { return importKey(keystore, *keyImport) })
+	case *keyExport != "":
+		commandOk = withKeystore(func(keystore *identity.Keystore) bool { return exportKey(keystore, *keyExport) })
+	case *keyHistory:
+		commandOk = forEachChain(pipelines, readOnly, printKeyHistory)
+	case *attestationKeyImport != "":
+		commandOk = withKeystore(func(keystore *identity.Keystore) bool {
+			return importAttestationKey(keystore, *attestationKeyImport, domain)
+		})
+	case *attestationVectors:
+		commandOk = printAttestationVectors()
+	case *rotateTo != "":
+		commandOk = withKeystore(func(keystore *identity.Keystore) bool { return rotateKey(keystore, *rotateTo, pipelines, options) })
+	case *hashVectors:
+		commandOk = printHashVectors()
+	case *verify:
+		commandOk = forEachChain(pipelines, readOnly, verifyChain)
+	case *exportDir != "":
+		commandOk = forEachChain(pipelines, readOnly, exportChain(*exportDir, *exportFormat))
+	case *importDir != "":
+		commandOk = forEachChain(pipelines, options, importChain(*importDir))
+	default:
+		keystore, err := openKeystore(*keystoreDir, *passphraseFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		node, err := nodeIdentity(keystore)
+		if err != nil {
+			log.Fatal(err)
+		}
+		log.Printf("Node address %s", node.Address())
+
+		var attester *attestation.Attester
+		if *attestations {
+			if attester, err = loadAttester(keystore, domain); err != nil {
+				log.Fatal(err)
+			}
+			log.Printf("Attestation address %s, for the contract %s in the chain %d", attester.Address(), domain.VerifyingContract, domain.ChainID)
+		}
+
+		runNode(pipelines, append(options, database.WithSigner(node)), *maxStaleness, attester)
+		return
+	}
+	if !commandOk {
+		os.Exit(1)
+	}
+}
+
+// Run the oracle until it is stopped
+func runNode(pipelines []mapreduce.PipelineConfig, options []database.Option, maxStaleness time.Duration, attester *attestation.Attester) {
+
+	// Prepare the pipelines to manage the request of sources, one for each market
+	supervisor := mapreduce.NewSupervisor(mapreduce.BlockchainFileLocation, publishedPrices)
+	supervisor.MaxStaleness = maxStaleness
+	supervisor.ChainOptions = options
+	supervisor.Attester = attester
+	for _, pipeline := range pipelines {
+		if _, err := supervisor.AddPipeline(pipeline); err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	// Prepare and run the subroutines for the oracle service
-	server := service.NewOracleServer(publishedPrices)
+	server := service.NewOracleServer(publishedPrices, supervisor, supervisor, supervisor, supervisor)
 	server.Initialize()
 
-	// Prepare and start the subroutines to manage the request of sources
-	processor := mapreduce.NewMapReduceProcessor(directory, quotedCurrency, publishedPrices)
-	processor.Initialize()
+	supervisor.Initialize()
+
+	// Release the chains before to exit
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+
+		log.Println("Stopping the pipelines...")
+		supervisor.Close()
+		os.Exit(0)
+	}()
 
 	// handler := cors.Default().Handler(mux)
 	err := http.ListenAndServe(":8080", nil)
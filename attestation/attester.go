@@ -0,0 +1,223 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package attestation
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/aquarelle-tech/darkmatter/identity"
+	"github.com/aquarelle-tech/darkmatter/types"
+	"github.com/decred/dcrd/dcrec/secp256k1/v3"
+	"github.com/decred/dcrd/dcrec/secp256k1/v3/ecdsa"
+)
+
+// KeystoreDir is the directory of the attestation keys, inside the keystore of the node
+const KeystoreDir = "attestation"
+
+// Size of the private keys and of the signatures (r, s and v)
+const (
+	PrivateKeySize = 32
+	SignatureSize  = 65
+)
+
+var (
+	// ErrInvalidAttestation is returned when an attestation doesn´t match its signer or its content
+	ErrInvalidAttestation = errors.New("the attestation is not valid")
+	// ErrAttestationsDisabled is returned when an attestation is requested from a node without attestation key
+	ErrAttestationsDisabled = errors.New("the attestations are not enabled in this node")
+	// ErrNoPrice is returned when an attestation is requested for a block without price, like a key rotation
+	ErrNoPrice = errors.New("the block has no price to attest")
+)
+
+// Attestation is the signed price of a block, ready to be sent to a contract. The values are the fields of the
+// typed data, and the signature is the 65 bytes r, s and v expected by ecrecover, with v 27 or 28
+type Attestation struct {
+	Ticker    string `json:"ticker"`
+	Price     string `json:"price"` // Fixed-point integer, in base 10
+	Decimals  int    `json:"decimals"`
+	Timestamp uint64 `json:"timestamp"`
+	Height    uint64 `json:"height"`
+	BlockHash string `json:"blockHash"` // Hash of the attested block. It is not signed
+	Signer    string `json:"signer"`    // Address of the oracle, with the EIP-55 checksum
+	Digest    string `json:"digest"`    // EIP-712 hash, in hexadecimal with 0x
+	Signature string `json:"signature"` // Hexadecimal with 0x
+}
+
+// Payload returns the typed data of the attestation
+func (a Attestation) Payload() (Payload, error) {
+	price, ok := new(big.Int).SetString(a.Price, 10)
+	if !ok {
+		return Payload{}, fmt.Errorf("invalid price %q", a.Price)
+	}
+	return Payload{Ticker: a.Ticker, Price: price, Timestamp: a.Timestamp, Height: a.Height}, nil
+}
+
+// Recover returns the address of the key which signed the attestation in the domain
+func (a Attestation) Recover(domain Domain) (string, error) {
+
+	payload, err := a.Payload()
+	if err != nil {
+		return "", err
+	}
+	digest, err := payload.Digest(domain)
+	if err != nil {
+		return "", err
+	}
+	signature, err := hex.DecodeString(strings.TrimPrefix(a.Signature, "0x"))
+	if err != nil || len(signature) != SignatureSize {
+		return "", ErrInvalidAttestation
+	}
+	v := signature[64]
+	if v != 27 && v != 28 {
+		return "", ErrInvalidAttestation
+	}
+
+	// The compact signatures of secp256k1 start with v, and the public key is recovered as uncompressed
+	compact := append([]byte{v}, signature[:64]...)
+	publicKey, _, err := ecdsa.RecoverCompact(compact, digest)
+	if err != nil {
+		return "", ErrInvalidAttestation
+	}
+
+	return AddressOf(publicKey), nil
+}
+
+// Verify checks that the attestation was signed in the domain by the owner of the address
+func (a Attestation) Verify(domain Domain, address string) error {
+
+	expected, err := ParseAddress(address)
+	if err != nil {
+		return err
+	}
+	signer, err := a.Recover(domain)
+	if err != nil {
+		return err
+	}
+	found, _ := ParseAddress(signer)
+	if !bytes.Equal(found, expected) {
+		return ErrInvalidAttestation
+	}
+
+	return nil
+}
+
+// AddressOf returns the Ethereum address of a public key: the last 20 bytes of the keccak256 of its coordinates
+func AddressOf(publicKey *secp256k1.PublicKey) string {
+	uncompressed := publicKey.SerializeUncompressed()
+	return ChecksumAddress(keccak256(uncompressed[1:])[12:])
+}
+
+// Attester signs the attestations of the blocks of a node
+type Attester struct {
+	Domain     Domain
+	privateKey *secp256k1.PrivateKey
+}
+
+// NewAttester creates an attester from a private key of 32 bytes
+func NewAttester(privateKey []byte, domain Domain) (*Attester, error) {
+	if len(privateKey) != PrivateKeySize {
+		return nil, fmt.Errorf("invalid private key length %d, expected %d bytes", len(privateKey), PrivateKeySize)
+	}
+	if _, err := domain.Separator(); err != nil {
+		return nil, err
+	}
+
+	key := secp256k1.PrivKeyFromBytes(privateKey)
+	if key.Key.IsZero() {
+		return nil, errors.New("invalid private key")
+	}
+	return &Attester{Domain: domain, privateKey: key}, nil
+}
+
+// Address returns the address of the attester, with the EIP-55 checksum. It is the address published for the
+// contracts
+func (a *Attester) Address() string {
+	return AddressOf(a.privateKey.PubKey())
+}
+
+// Sign signs a payload. The signatures are deterministic (RFC 6979), so the same payload always has the same
+// attestation
+func (a *Attester) Sign(payload Payload) (Attestation, error) {
+
+	digest, err := payload.Digest(a.Domain)
+	if err != nil {
+		return Attestation{}, err
+	}
+	signature := a.signDigest(digest)
+
+	return Attestation{
+		Ticker:    payload.Ticker,
+		Price:     payload.Price.String(),
+		Decimals:  types.FixedPointDecimals,
+		Timestamp: payload.Timestamp,
+		Height:    payload.Height,
+		Signer:    a.Address(),
+		Digest:    "0x" + hex.EncodeToString(digest),
+		Signature: "0x" + hex.EncodeToString(signature),
+	}, nil
+}
+
+// Signs an EIP-712 digest. The signature is r, s and v, as expected by ecrecover
+func (a *Attester) signDigest(digest []byte) []byte {
+	// The compact signature is v, r and s, where v is 27 or 28 for the uncompressed public keys
+	compact := ecdsa.SignCompact(a.privateKey, digest, false)
+	return append(compact[1:], compact[0])
+}
+
+// Attest signs the price of a block
+func (a *Attester) Attest(block types.FullSignedBlock) (Attestation, error) {
+
+	payload, err := PayloadOf(block)
+	if err != nil {
+		return Attestation{}, err
+	}
+	attestation, err := a.Sign(payload)
+	attestation.BlockHash = block.Hash
+
+	return attestation, err
+}
+
+// LoadOrCreate returns the attester of the active key of a keystore, creating the key if the keystore is empty.
+// The keystore keeps the private keys of 32 bytes, with their Ethereum addresses
+func LoadOrCreate(keystore *identity.Keystore, domain Domain) (*Attester, error) {
+
+	address, err := keystore.ActiveAddress()
+	if err == nil {
+		privateKey, err := keystore.LoadSecret(address)
+		if err != nil {
+			return nil, err
+		}
+		return NewAttester(privateKey, domain)
+	}
+	if err != identity.ErrNoActiveKey {
+		return nil, err
+	}
+	if addresses, err := keystore.Addresses(); err != nil || len(addresses) > 0 {
+		return nil, fmt.Errorf("the keystore %s has no active key", keystore.Dir)
+	}
+
+	key, err := secp256k1.GeneratePrivateKey()
+	if err != nil {
+		return nil, err
+	}
+	return Import(keystore, key.Serialize(), domain)
+}
+
+// Import stores a private key in a keystore and activates it
+func Import(keystore *identity.Keystore, privateKey []byte, domain Domain) (*Attester, error) {
+
+	attester, err := NewAttester(privateKey, domain)
+	if err != nil {
+		return nil, err
+	}
+	if err := keystore.StoreSecret(attester.Address(), privateKey); err != nil {
+		return nil, err
+	}
+	return attester, keystore.SetActive(attester.Address())
+}
@@ -0,0 +1,71 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package attestation
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+// Published test vectors of the attestations
+const attestationVectorsFile = "../docs/attestation-vectors.json"
+
+func TestAttestationVectors(t *testing.T) {
+
+	vectors, err := TestVectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	published, err := ioutil.ReadFile(attestationVectorsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published = bytes.ReplaceAll(published, []byte("\r\n"), []byte("\n"))
+
+	// Encoded as they are published, with -attestation-vectors
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(vectors); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(buffer.Bytes(), published) {
+		t.Fatalf("the vectors don´t match %s, run the node with -attestation-vectors to publish them again", attestationVectorsFile)
+	}
+}
+
+func TestAttestationVectorsVerify(t *testing.T) {
+
+	vectors, err := TestVectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, vector := range vectors.Vectors {
+		signed := vector.Attestation
+		if err := signed.Verify(vector.Domain, vectors.Signer); err != nil {
+			t.Errorf("vector %d: %v", i, err)
+		}
+		signer, err := signed.Recover(vector.Domain)
+		if err != nil {
+			t.Errorf("vector %d: %v", i, err)
+		} else if signer != vectors.Signer {
+			t.Errorf("vector %d: recovered %s, want %s", i, signer, vectors.Signer)
+		}
+
+		// The signature covers the price and the domain
+		tampered := signed
+		tampered.Price += "0"
+		if err := tampered.Verify(vector.Domain, vectors.Signer); err == nil {
+			t.Errorf("vector %d: verified with another price", i)
+		}
+		domain := vector.Domain
+		domain.ChainID++
+		if err := signed.Verify(domain, vectors.Signer); err == nil {
+			t.Errorf("vector %d: verified in another domain", i)
+		}
+	}
+}
@@ -0,0 +1,162 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+
+// Package attestation signs the prices of the blocks with a secp256k1 key, as EIP-712 typed data, so they can be
+// verified by the smart contracts with ecrecover
+package attestation
+
+import (
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+	"golang.org/x/crypto/sha3"
+)
+
+// Types of the EIP-712 typed data. The price is the fixed-point integer of the canonical hash, with
+// types.FixedPointDecimals decimals
+const (
+	DomainType = "EIP712Domain(string name,string version,uint256 chainId,address verifyingContract)"
+	PriceType  = "PriceAttestation(string ticker,int256 price,uint64 timestamp,uint64 height)"
+)
+
+// Hashes of the types
+var (
+	domainTypeHash = keccak256([]byte(DomainType))
+	priceTypeHash  = keccak256([]byte(PriceType))
+)
+
+// Domain is the EIP-712 domain of the attestations. It must match the domain of the contract which verifies them
+type Domain struct {
+	Name              string `json:"name"`
+	Version           string `json:"version"`
+	ChainID           uint64 `json:"chainId"`
+	VerifyingContract string `json:"verifyingContract"` // Address of the contract, as 0x and 40 hexadecimal characters
+}
+
+// DefaultDomain is the domain used when no chain or contract is set
+var DefaultDomain = Domain{
+	Name:              "DarkMatter Oracle",
+	Version:           "1",
+	ChainID:           1,
+	VerifyingContract: "0x0000000000000000000000000000000000000000",
+}
+
+// Payload is the typed data signed for a block
+type Payload struct {
+	Ticker    string   // Ticker of the market, like BTCUSD
+	Price     *big.Int // Average price of the block, as a fixed-point integer
+	Timestamp uint64   // Timestamp of the block, in Unix seconds
+	Height    uint64   // Height of the block in the chain of the market
+}
+
+// PayloadOf returns the payload of the attestation of a block. The key rotation blocks have no price: they return
+// ErrNoPrice
+func PayloadOf(block types.FullSignedBlock) (Payload, error) {
+	if block.IsKeyRotation() {
+		return Payload{}, ErrNoPrice
+	}
+	price, err := types.FixedPoint(block.AveragePrice)
+	if err != nil {
+		return Payload{}, err
+	}
+
+	return Payload{
+		Ticker:    block.Ticker,
+		Price:     price,
+		Timestamp: block.Timestamp,
+		Height:    block.Height,
+	}, nil
+}
+
+func keccak256(data ...[]byte) []byte {
+	hash := sha3.NewLegacyKeccak256()
+	for _, bytes := range data {
+		hash.Write(bytes)
+	}
+	return hash.Sum(nil)
+}
+
+// Encodes an integer as an ABI word: 32 bytes, big-endian, in two's complement
+func word(value *big.Int) []byte {
+	var bytes [32]byte
+	if value.Sign() < 0 {
+		value = new(big.Int).Add(value, new(big.Int).Lsh(big.NewInt(1), 256))
+	}
+	raw := value.Bytes()
+	copy(bytes[32-len(raw):], raw)
+	return bytes[:]
+}
+
+func uint64Word(value uint64) []byte {
+	return word(new(big.Int).SetUint64(value))
+}
+
+// ParseAddress decodes an Ethereum address, as 0x and 40 hexadecimal characters. The checksum of the mixed case
+// addresses is not verified
+func ParseAddress(address string) ([]byte, error) {
+	raw, err := hex.DecodeString(strings.TrimPrefix(address, "0x"))
+	if err != nil || len(raw) != 20 || !strings.HasPrefix(address, "0x") {
+		return nil, fmt.Errorf("invalid address %q", address)
+	}
+	return raw, nil
+}
+
+// ChecksumAddress formats an address with the mixed case checksum of EIP-55
+func ChecksumAddress(raw []byte) string {
+	lower := hex.EncodeToString(raw)
+	hash := keccak256([]byte(lower))
+
+	checksum := []byte(lower)
+	for i, c := range checksum {
+		// The letters are uppercase when the nibble of the hash in their position is 8 or more
+		nibble := hash[i/2] >> 4
+		if i%2 == 1 {
+			nibble = hash[i/2] & 0x0f
+		}
+		if c >= 'a' && nibble >= 8 {
+			checksum[i] = c - 'a' + 'A'
+		}
+	}
+
+	return "0x" + string(checksum)
+}
+
+// Separator returns the hash of the domain
+func (d Domain) Separator() ([]byte, error) {
+	contract, err := ParseAddress(d.VerifyingContract)
+	if err != nil {
+		return nil, err
+	}
+
+	return keccak256(
+		domainTypeHash,
+		keccak256([]byte(d.Name)),
+		keccak256([]byte(d.Version)),
+		uint64Word(d.ChainID),
+		word(new(big.Int).SetBytes(contract)),
+	), nil
+}
+
+// StructHash returns the hash of the typed data of the payload
+func (p Payload) StructHash() []byte {
+	return keccak256(
+		priceTypeHash,
+		keccak256([]byte(p.Ticker)),
+		word(p.Price),
+		uint64Word(p.Timestamp),
+		uint64Word(p.Height),
+	)
+}
+
+// Digest returns the EIP-712 hash signed for the payload in the domain: keccak256(0x19 0x01 separator structHash)
+func (p Payload) Digest(domain Domain) ([]byte, error) {
+	separator, err := domain.Separator()
+	if err != nil {
+		return nil, err
+	}
+	return keccak256([]byte{0x19, 0x01}, separator, p.StructHash()), nil
+}
@@ -0,0 +1,90 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package attestation
+
+import (
+	"encoding/hex"
+	"math/big"
+	"testing"
+)
+
+// The "Ether Mail" example of the EIP-712 specification, with its published hashes and signature
+var etherMailDomain = Domain{
+	Name:              "Ether Mail",
+	Version:           "1",
+	ChainID:           1,
+	VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC",
+}
+
+const (
+	etherMailSeparator  = "f2cee375fa42b42143804025fc449deafd50cc031ca257e0b194a650a912090f"
+	etherMailStructHash = "c52c0ee5d84264471806290a3f2c4cecfc5490626bf912d01f240d7a274b371e"
+	etherMailDigest     = "be609aee343fb3c4b28e1df9e632fca64fcfaede20f02e86244efddf30957bd2"
+	etherMailSigner     = "0xCD2a3d9F938E13CD947Ec05AbC7FE734Df8DD826"
+	// r, s and v
+	etherMailSignature = "4355c47d63924e8a72e509b65029052eb6c299d53a04e167c5775fd466751c9d" +
+		"07299936d304c153f6443dfa05f40ff007d72911b6f72307f996231605b91562" + "1c"
+)
+
+// Struct hash of a Person(string name,address wallet) of the example
+func etherMailPerson(t *testing.T, name string, wallet string) []byte {
+	t.Helper()
+
+	address, err := ParseAddress(wallet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keccak256(
+		keccak256([]byte("Person(string name,address wallet)")),
+		keccak256([]byte(name)),
+		word(new(big.Int).SetBytes(address)),
+	)
+}
+
+func TestEtherMailSeparator(t *testing.T) {
+
+	separator, err := etherMailDomain.Separator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(separator) != etherMailSeparator {
+		t.Errorf("Separator() = %x, expected %s", separator, etherMailSeparator)
+	}
+}
+
+func TestEtherMailSignature(t *testing.T) {
+
+	// The private key of the example is keccak256("cow")
+	attester, err := NewAttester(keccak256([]byte("cow")), etherMailDomain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if attester.Address() != etherMailSigner {
+		t.Fatalf("Address() = %s, expected %s", attester.Address(), etherMailSigner)
+	}
+
+	structHash := keccak256(
+		keccak256([]byte("Mail(Person from,Person to,string contents)Person(string name,address wallet)")),
+		etherMailPerson(t, "Cow", etherMailSigner),
+		etherMailPerson(t, "Bob", "0xbBbBBBBbbBBBbbbBbbBbbbbBBbBbbbbBbBbbBBbB"),
+		keccak256([]byte("Hello, Bob!")),
+	)
+	if hex.EncodeToString(structHash) != etherMailStructHash {
+		t.Fatalf("struct hash = %x, expected %s", structHash, etherMailStructHash)
+	}
+	separator, err := etherMailDomain.Separator()
+	if err != nil {
+		t.Fatal(err)
+	}
+	digest := keccak256([]byte{0x19, 0x01}, separator, structHash)
+	if hex.EncodeToString(digest) != etherMailDigest {
+		t.Fatalf("digest = %x, expected %s", digest, etherMailDigest)
+	}
+
+	// The signatures are deterministic (RFC 6979), as the one of the example
+	signature := attester.signDigest(digest)
+	if hex.EncodeToString(signature) != etherMailSignature {
+		t.Errorf("signature = %x, expected %s", signature, etherMailSignature)
+	}
+}
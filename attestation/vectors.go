@@ -0,0 +1,99 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package attestation
+
+import (
+	"encoding/hex"
+	"math/big"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// vectorKey is the private key that signs the test vectors. It must never be used by a node
+var vectorKey = []byte{
+	0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+	0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+}
+
+// Vector is a test vector of an attestation, with the intermediate hashes
+type Vector struct {
+	Domain          Domain      `json:"domain"`
+	DomainSeparator string      `json:"domainSeparator"` // Hexadecimal with 0x
+	StructHash      string      `json:"structHash"`      // Hexadecimal with 0x
+	Attestation     Attestation `json:"attestation"`
+}
+
+// Vectors are the published test vectors of the attestations, so the contracts and other implementations can
+// check their results. They are written to docs/attestation-vectors.json
+type Vectors struct {
+	DomainType string   `json:"domainType"`
+	PriceType  string   `json:"priceType"`
+	PrivateKey string   `json:"privateKey"` // Key of the signer, in hexadecimal with 0x
+	Signer     string   `json:"signer"`
+	Vectors    []Vector `json:"vectors"`
+}
+
+// TestVectors builds the test vectors of the attestations. The blocks are the ones of the hash test vectors
+func TestVectors() (Vectors, error) {
+
+	vectors := Vectors{
+		DomainType: DomainType,
+		PriceType:  PriceType,
+		PrivateKey: "0x" + hex.EncodeToString(vectorKey),
+	}
+	hashVectors, err := types.CanonicalHashVectors()
+	if err != nil {
+		return vectors, err
+	}
+
+	// The blocks of the current hash version. The key rotation blocks have no price
+	current := hashVectors.Versions[len(hashVectors.Versions)-1]
+	var payloads []Payload
+	var blockHashes []string
+	for _, vector := range current.Blocks {
+		if vector.Block.IsKeyRotation() {
+			continue
+		}
+		payload, err := PayloadOf(vector.Block)
+		if err != nil {
+			return vectors, err
+		}
+		payloads = append(payloads, payload)
+		blockHashes = append(blockHashes, vector.Hash)
+	}
+	// The prices are signed integers
+	payloads = append(payloads, Payload{Ticker: "ETHBTC", Price: big.NewInt(-5), Timestamp: 1573257615, Height: 3})
+
+	domains := []Domain{
+		DefaultDomain,
+		{Name: "DarkMatter Oracle", Version: "1", ChainID: 11155111, VerifyingContract: "0xCcCCccccCCCCcCCCCCCcCcCccCcCCCcCcccccccC"},
+	}
+	for _, domain := range domains {
+		attester, err := NewAttester(vectorKey, domain)
+		if err != nil {
+			return vectors, err
+		}
+		vectors.Signer = attester.Address()
+		separator, _ := domain.Separator()
+
+		for i, payload := range payloads {
+			attestation, err := attester.Sign(payload)
+			if err != nil {
+				return vectors, err
+			}
+			if i < len(blockHashes) {
+				attestation.BlockHash = blockHashes[i]
+			}
+
+			vectors.Vectors = append(vectors.Vectors, Vector{
+				Domain:          domain,
+				DomainSeparator: "0x" + hex.EncodeToString(separator),
+				StructHash:      "0x" + hex.EncodeToString(payload.StructHash()),
+				Attestation:     attestation,
+			})
+		}
+	}
+
+	return vectors, nil
+}
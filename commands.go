@@ -0,0 +1,173 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/aquarelle-tech/darkmatter/attestation"
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/mapreduce"
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// Formats of the exported chains
+const (
+	NDJSONFormat = "ndjson" // One block per line, with the full evidence. It can be imported
+	CSVFormat    = "csv"    // A file for the blocks and another one for the evidence, one row for each
+)
+
+// Print the test vectors of the canonical hash scheme
+func printHashVectors() bool {
+
+	vectors, err := types.CanonicalHashVectors()
+	if err != nil {
+		log.Printf("Unable to create the hash vectors: %v", err)
+		return false
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(vectors); err != nil {
+		log.Printf("Unable to write the hash vectors: %v", err)
+		return false
+	}
+
+	return true
+}
+
+// Print the test vectors of the attestations
+func printAttestationVectors() bool {
+
+	vectors, err := attestation.TestVectors()
+	if err != nil {
+		log.Printf("Unable to create the attestation vectors: %v", err)
+		return false
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(vectors); err != nil {
+		log.Printf("Unable to write the attestation vectors: %v", err)
+		return false
+	}
+
+	return true
+}
+
+// A command over the chain of a market
+type chainCommand func(market types.Market, chain *database.BlockChain) error
+
+// Run a command over the stored chains of the markets. Returns false if it fails for any chain
+func forEachChain(pipelines []mapreduce.PipelineConfig, options []database.Option, command chainCommand) bool {
+	ok := true
+
+	for _, pipeline := range pipelines {
+		ticker := pipeline.Market.Ticker()
+		chain, err := database.NewBlockChain(ticker, filepath.Join(mapreduce.BlockchainFileLocation, ticker), options...)
+		if err != nil {
+			log.Printf("Can´t open the chain of %s: %v", pipeline.Market, err)
+			ok = false
+			continue
+		}
+
+		if err := command(pipeline.Market, chain); err != nil {
+			log.Printf("Error with the chain of %s: %v", pipeline.Market, err)
+			ok = false
+		}
+		chain.Close()
+	}
+
+	return ok
+}
+
+// Verify a chain, from the genesis block to the latest one
+func verifyChain(market types.Market, chain *database.BlockChain) error {
+
+	report, err := chain.Verify()
+	if err != nil {
+		return fmt.Errorf("the chain is NOT valid after %d blocks: %v", report.Blocks, err)
+	}
+	log.Printf("The chain of %s is valid: %d blocks and %d sources, latest %s", market, report.Blocks, report.Sources, report.LatestHash)
+
+	return nil
+}
+
+// Write a file with one of the exports of a chain
+func exportFile(path string, export func(w io.Writer) (int, error)) (int, error) {
+
+	file, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+
+	count, err := export(file)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+
+	return count, err
+}
+
+// Export a chain to files in the directory, named as the ticker of the market
+func exportChain(directory string, format string) chainCommand {
+	return func(market types.Market, chain *database.BlockChain) error {
+
+		if err := os.MkdirAll(directory, 0700); err != nil {
+			return err
+		}
+		base := filepath.Join(directory, market.Ticker())
+
+		switch format {
+		case NDJSONFormat:
+			count, err := exportFile(base+".ndjson", chain.ExportNDJSON)
+			if err != nil {
+				return err
+			}
+			log.Printf("Exported %d blocks of %s to %s.ndjson", count, market, base)
+
+		case CSVFormat:
+			blocks, err := exportFile(base+"-blocks.csv", chain.ExportBlocksCSV)
+			if err != nil {
+				return err
+			}
+			sources, err := exportFile(base+"-evidence.csv", chain.ExportEvidenceCSV)
+			if err != nil {
+				return err
+			}
+			log.Printf("Exported %d blocks and %d sources of %s to %s-blocks.csv and %s-evidence.csv", blocks, sources, market, base, base)
+
+		default:
+			return fmt.Errorf("unknown export format %q", format)
+		}
+
+		return nil
+	}
+}
+
+// Import a chain from the ndjson file in the directory named as the ticker of the market
+func importChain(directory string) chainCommand {
+	return func(market types.Market, chain *database.BlockChain) error {
+
+		path := filepath.Join(directory, market.Ticker()+".ndjson")
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		count, err := chain.ImportNDJSON(file)
+		if err != nil {
+			return fmt.Errorf("import stopped after %d blocks: %v", count, err)
+		}
+		log.Printf("Imported %d blocks of %s from %s", count, market, path)
+
+		return nil
+	}
+}
@@ -4,7 +4,9 @@
 package crawlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"strconv"
 	"time"
 
@@ -13,22 +15,37 @@ import (
 
 const (
 	BINANCE_MODULE_NAME = "Binance REST API"
-	BINANCE_APIURL      = "https://api.binance.com/api/v3/ticker/24hr?symbol=BTCUSDT"
+	BINANCE_APIURL      = "https://api.binance.com/api/v3/ticker/24hr?symbol=%s"
 )
 
+// BinanceMarkets are the markets available in Binance. There are no USD markets, so USDT is used instead
+var BinanceMarkets = Markets{
+	"BTCUSD": {Symbol: "BTCUSDT", Quote: "USDT"},
+	"ETHUSD": {Symbol: "ETHUSDT", Quote: "USDT"},
+	"BTCEUR": {Symbol: "BTCEUR"},
+	"ETHEUR": {Symbol: "ETHEUR"},
+	"ETHBTC": {Symbol: "ETHBTC"},
+}
+
 // The REST API client to get data from Binance
 type BinanceCrawler struct {
 	DataCrawler Crawler
-	Ticker      string
+	Market      types.Market
+	Pair        Pair
 }
 
-// Creates a new crawler
-func NewBinanceCrawler() BinanceCrawler {
-	crawler := NewCrawler(BINANCE_APIURL)
+// Creates a new crawler for a market
+func NewBinanceCrawler(market types.Market) (BinanceCrawler, error) {
+	pair, err := BinanceMarkets.Resolve(BINANCE_MODULE_NAME, market)
+	if err != nil {
+		return BinanceCrawler{}, err
+	}
 
 	return BinanceCrawler{
-		DataCrawler: crawler,
-	}
+		DataCrawler: NewCrawler(fmt.Sprintf(BINANCE_APIURL, pair.Symbol)),
+		Market:      market,
+		Pair:        pair,
+	}, nil
 }
 
 // Return the name of this crawler
@@ -37,53 +54,58 @@ func (c BinanceCrawler) GetName() string {
 }
 
 func (c BinanceCrawler) GetTicker() string {
-	return c.Ticker
+	return c.Pair.Symbol
+}
+
+func (c BinanceCrawler) GetMarket() types.Market {
+	return c.Market
 }
 
 // Serializes a json to a TickerInfo24 type
-func (c BinanceCrawler) ToQuotePriceInfo(jsonData []byte) types.QuotePriceInfo {
+func (c BinanceCrawler) ToQuotePriceInfo(jsonData []byte) (types.QuotePriceInfo, error) {
 
 	var result types.QuotePriceInfo
 	aux := struct {
 		Volume      string `json:"volume"`
 		QuoteVolume string `json:"quoteVolume"`
 		HighPrice   string `json:"highPrice"`
+		LastPrice   string `json:"lastPrice"`
 		OpenPrice   string `json:"openPrice"`
 	}{}
 
 	if err := json.Unmarshal(jsonData, &aux); err != nil {
-		panic(err)
+		return result, err
 	}
 
+	var err error
 	result = types.QuotePriceInfo{}
+	// The last price is the one used by the index, so it must be valid
+	if result.LastPrice, err = strconv.ParseFloat(aux.LastPrice, 64); err != nil {
+		return result, fmt.Errorf("invalid last price %q", aux.LastPrice)
+	}
 	result.Volume, _ = strconv.ParseFloat(aux.Volume, 32)
 	result.QuoteVolume, _ = strconv.ParseFloat(aux.QuoteVolume, 32)
 	result.HighPrice, _ = strconv.ParseFloat(aux.HighPrice, 32)
 	result.OpenPrice, _ = strconv.ParseFloat(aux.OpenPrice, 32)
+	result.QuoteCurrency = c.Pair.Quote
 
-	return result
-}
-
-// Set the ticker name according the quoted currency requested
-func (c BinanceCrawler) SetTicker(quotedCurrency string) {
-
-	switch quotedCurrency {
-	case "USD":
-		c.Ticker = "BTCUSDT"
-	}
+	return result, nil
 }
 
 // Helper function to convert the json from Binance´s API to a QuotePriceInfo instance
-func (c BinanceCrawler) Crawl(quotedCurrency string, done chan types.QuotePriceInfo) {
+func (c BinanceCrawler) Crawl(ctx context.Context) (types.QuotePriceInfo, error) {
 
-	c.SetTicker(quotedCurrency)
-	jsonData, err := c.DataCrawler.Get()
+	jsonData, err := c.DataCrawler.Get(ctx)
 	if err != nil {
-		return
+		return types.QuotePriceInfo{}, err
 	}
 
-	priceInfo := c.ToQuotePriceInfo(jsonData)
+	priceInfo, err := c.ToQuotePriceInfo(jsonData)
+	if err != nil {
+		return priceInfo, err
+	}
 	priceInfo.Timestamp = time.Now().Unix()
-	priceInfo.DataURL = BINANCE_APIURL
-	done <- priceInfo
+	priceInfo.DataURL = c.DataCrawler.Url
+
+	return priceInfo, nil
 }
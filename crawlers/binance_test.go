@@ -0,0 +1,45 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"testing"
+)
+
+func TestBinanceToQuotePriceInfo(t *testing.T) {
+
+	crawler, err := NewBinanceCrawler(parseMarket(t, "BTC/USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		ticker  []byte
+		wantErr bool
+		last    float64
+	}{
+		{name: "sample", ticker: readSample(t, "binance_24.json"), last: 9041.5},
+		// A 32 bits float would round the price to 9041.4697265625
+		{name: "cents", ticker: []byte(`{"lastPrice": "9041.47000000", "volume": "1"}`), last: 9041.47},
+		{name: "invalid last price", ticker: []byte(`{"lastPrice": "", "volume": "1"}`), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := crawler.ToQuotePriceInfo(test.ticker)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.LastPrice != test.last {
+				t.Errorf("LastPrice = %v, want %v", info.LastPrice, test.last)
+			}
+		})
+	}
+}
@@ -4,8 +4,9 @@
 package crawlers
 
 import (
+	"context"
 	"encoding/json"
-	"reflect"
+	"fmt"
 	"time"
 
 	"github.com/aquarelle-tech/darkmatter/types"
@@ -13,22 +14,38 @@ import (
 
 const (
 	BITFINEX_MODULE_NAME = "Bitfinex REST API"
-	BITFINEX_APIURL      = "https://api-pub.bitfinex.com/v2/ticker/tBTCUSD"
+	BITFINEX_APIURL      = "https://api-pub.bitfinex.com/v2/ticker/%s"
 )
 
+// BitfinexMarkets are the markets available in Bitfinex. The trading pairs are prefixed with a "t"
+var BitfinexMarkets = Markets{
+	"BTCUSD": {Symbol: "tBTCUSD"},
+	"ETHUSD": {Symbol: "tETHUSD"},
+	"BTCEUR": {Symbol: "tBTCEUR"},
+	"ETHEUR": {Symbol: "tETHEUR"},
+	"BTCJPY": {Symbol: "tBTCJPY"},
+	"ETHBTC": {Symbol: "tETHBTC"},
+}
+
 // The REST API client to get data from Bitfinex
 type BitfinexCrawler struct {
 	DataCrawler Crawler
-	Ticker      string
+	Market      types.Market
+	Pair        Pair
 }
 
-// Creates a new crawler
-func NewBitfinexCrawler() BitfinexCrawler {
-	crawler := NewCrawler(BITFINEX_APIURL)
+// Creates a new crawler for a market
+func NewBitfinexCrawler(market types.Market) (BitfinexCrawler, error) {
+	pair, err := BitfinexMarkets.Resolve(BITFINEX_MODULE_NAME, market)
+	if err != nil {
+		return BitfinexCrawler{}, err
+	}
 
 	return BitfinexCrawler{
-		DataCrawler: crawler,
-	}
+		DataCrawler: NewCrawler(fmt.Sprintf(BITFINEX_APIURL, pair.Symbol)),
+		Market:      market,
+		Pair:        pair,
+	}, nil
 }
 
 // Return the name of this crawler
@@ -37,54 +54,51 @@ func (c BitfinexCrawler) GetName() string {
 }
 
 func (c BitfinexCrawler) GetTicker() string {
-	return c.Ticker
+	return c.Pair.Symbol
+}
+
+func (c BitfinexCrawler) GetMarket() types.Market {
+	return c.Market
 }
 
 // Serializes a json to a TickerInfo24 type
-func (c BitfinexCrawler) ToQuotePriceInfo(jsonData []byte) types.QuotePriceInfo {
+func (c BitfinexCrawler) ToQuotePriceInfo(jsonData []byte) (types.QuotePriceInfo, error) {
 
 	var result types.QuotePriceInfo
 
-	aux := make([]interface{}, 16)
+	// [BID, BID_SIZE, ASK, ASK_SIZE, DAILY_CHANGE, DAILY_CHANGE_RELATIVE, LAST_PRICE, VOLUME, HIGH, LOW]
+	var aux []float64
 	if err := json.Unmarshal(jsonData, &aux); err != nil {
-		panic(err)
+		return result, err
+	}
+	if len(aux) < 10 {
+		return result, fmt.Errorf("unexpected ticker format from Bitfinex: %s", jsonData)
 	}
 
 	result = types.QuotePriceInfo{}
-	result.Volume = getFloat(aux[7])
-	result.HighPrice = getFloat(aux[8])
-	// result.OpenPrice, _ = strconv.ParseFloat(aux.OpenPrice, 32)
-
-	return result
-}
-
-func getFloat(unk interface{}) float64 {
-	v := reflect.ValueOf(unk)
-	floatType := reflect.TypeOf(float64(0))
-	fv := v.Convert(floatType)
-	return fv.Float()
-}
+	result.LastPrice = aux[6]
+	result.Volume = aux[7]
+	result.HighPrice = aux[8]
+	result.OpenPrice = result.LastPrice - aux[4] // The ticker only has the daily change
+	result.QuoteCurrency = c.Pair.Quote
 
-func (c BitfinexCrawler) SetTicker(quotedCurrency string) {
-
-	switch quotedCurrency {
-	case "USD":
-		c.Ticker = "BTCUSD"
-	}
+	return result, nil
 }
 
 // Helper function to convert the json from Bitfinex´s API to a QuotePriceInfo instance
-func (c BitfinexCrawler) Crawl(quotedCurrency string, done chan types.QuotePriceInfo) {
-
-	c.SetTicker(quotedCurrency)
-	jsonData, err := c.DataCrawler.Get()
+func (c BitfinexCrawler) Crawl(ctx context.Context) (types.QuotePriceInfo, error) {
 
+	jsonData, err := c.DataCrawler.Get(ctx)
 	if err != nil {
-		return
+		return types.QuotePriceInfo{}, err
 	}
 
-	priceInfo := c.ToQuotePriceInfo(jsonData)
+	priceInfo, err := c.ToQuotePriceInfo(jsonData)
+	if err != nil {
+		return priceInfo, err
+	}
 	priceInfo.Timestamp = time.Now().Unix()
-	priceInfo.DataURL = BITFINEX_APIURL
-	done <- priceInfo
+	priceInfo.DataURL = c.DataCrawler.Url
+
+	return priceInfo, nil
 }
@@ -0,0 +1,110 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+const (
+	BITFLYER_MODULE_NAME = "bitFlyer REST API"
+	BITFLYER_APIURL      = "https://api.bitflyer.com/v1/ticker?product_code=%s"
+)
+
+// BitflyerMarkets are the products available in bitFlyer
+var BitflyerMarkets = Markets{
+	"BTCJPY": {Symbol: "BTC_JPY"},
+	"ETHJPY": {Symbol: "ETH_JPY"},
+	"BTCUSD": {Symbol: "BTC_USD"},
+	"BTCEUR": {Symbol: "BTC_EUR"},
+	"ETHBTC": {Symbol: "ETH_BTC"},
+}
+
+// The REST API client to get data from bitFlyer
+type BitflyerCrawler struct {
+	DataCrawler Crawler
+	Market      types.Market
+	Pair        Pair
+}
+
+// Creates a new crawler for a market
+func NewBitflyerCrawler(market types.Market) (BitflyerCrawler, error) {
+	pair, err := BitflyerMarkets.Resolve(BITFLYER_MODULE_NAME, market)
+	if err != nil {
+		return BitflyerCrawler{}, err
+	}
+
+	return BitflyerCrawler{
+		DataCrawler: NewCrawler(fmt.Sprintf(BITFLYER_APIURL, pair.Symbol)),
+		Market:      market,
+		Pair:        pair,
+	}, nil
+}
+
+// Return the name of this crawler
+func (c BitflyerCrawler) GetName() string {
+	return BITFLYER_MODULE_NAME
+}
+
+func (c BitflyerCrawler) GetTicker() string {
+	return c.Pair.Symbol
+}
+
+func (c BitflyerCrawler) GetMarket() types.Market {
+	return c.Market
+}
+
+// Serializes a json to a QuotePriceInfo type. The prices are quoted in the currency of the product (BTC_JPY => JPY)
+func (c BitflyerCrawler) ToQuotePriceInfo(jsonData []byte) (types.QuotePriceInfo, error) {
+
+	var result types.QuotePriceInfo
+	aux := struct {
+		ProductCode string  `json:"product_code"`
+		BidPrice    float64 `json:"best_bid"`
+		AskPrice    float64 `json:"best_ask"`
+		LastPrice   float64 `json:"ltp"`
+		Volume      float64 `json:"volume_by_product"`
+	}{}
+
+	if err := json.Unmarshal(jsonData, &aux); err != nil {
+		return result, err
+	}
+	if aux.ProductCode != c.Pair.Symbol {
+		return result, fmt.Errorf("unexpected product %s from bitFlyer, expected %s", aux.ProductCode, c.Pair.Symbol)
+	}
+
+	result = types.QuotePriceInfo{}
+	result.LastPrice = aux.LastPrice
+	result.BidPrice = aux.BidPrice
+	result.AskPrice = aux.AskPrice
+	result.Volume = aux.Volume
+	// bitFlyer doesn´t publish the quote volume, so it is estimated with the latest price
+	result.QuoteVolume = result.Volume * result.LastPrice
+	result.QuoteCurrency = c.Pair.Quote
+
+	return result, nil
+}
+
+// Helper function to convert the json from bitFlyer´s API to a QuotePriceInfo instance
+func (c BitflyerCrawler) Crawl(ctx context.Context) (types.QuotePriceInfo, error) {
+
+	jsonData, err := c.DataCrawler.Get(ctx)
+	if err != nil {
+		return types.QuotePriceInfo{}, err
+	}
+
+	priceInfo, err := c.ToQuotePriceInfo(jsonData)
+	if err != nil {
+		return priceInfo, err
+	}
+	priceInfo.Timestamp = time.Now().Unix()
+	priceInfo.DataURL = c.DataCrawler.Url
+
+	return priceInfo, nil
+}
@@ -0,0 +1,59 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"testing"
+)
+
+func TestBitflyerToQuotePriceInfo(t *testing.T) {
+
+	ticker := readSample(t, "bitflyer_ticker.json")
+
+	tests := []struct {
+		name    string
+		market  string
+		ticker  []byte
+		wantErr bool
+	}{
+		{name: "sample", market: "BTC/JPY", ticker: ticker},
+		{name: "other product", market: "BTC/USD", ticker: ticker, wantErr: true},
+		{name: "invalid answer", market: "BTC/JPY", ticker: []byte(`{"product_code": "BTC_JPY", "ltp": "955400"}`), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			crawler, err := NewBitflyerCrawler(parseMarket(t, test.market))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := crawler.ToQuotePriceInfo(test.ticker)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.LastPrice != 955400 || info.BidPrice != 955151 || info.AskPrice != 955400 {
+				t.Errorf("unexpected prices: %+v", info)
+			}
+			// The volume of the product, not the volume of all the markets of bitFlyer
+			if info.Volume != 4513.00315364 {
+				t.Errorf("Volume = %v, want 4513.00315364", info.Volume)
+			}
+			if info.QuoteVolume != info.Volume*info.LastPrice {
+				t.Errorf("QuoteVolume = %v, want the volume at the last price %v", info.QuoteVolume, info.Volume*info.LastPrice)
+			}
+			// The currency of the product code
+			if info.QuoteCurrency != "JPY" {
+				t.Errorf("QuoteCurrency = %q, want JPY", info.QuoteCurrency)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+const (
+	BITSTAMP_MODULE_NAME = "Bitstamp REST API"
+	BITSTAMP_APIURL      = "https://www.bitstamp.net/api/v2/ticker/%s/"
+)
+
+// BitstampMarkets are the markets available in Bitstamp
+var BitstampMarkets = Markets{
+	"BTCUSD": {Symbol: "btcusd"},
+	"ETHUSD": {Symbol: "ethusd"},
+	"BTCEUR": {Symbol: "btceur"},
+	"ETHEUR": {Symbol: "etheur"},
+	"BTCGBP": {Symbol: "btcgbp"},
+	"ETHBTC": {Symbol: "ethbtc"},
+}
+
+// The REST API client to get data from Bitstamp
+type BitstampCrawler struct {
+	DataCrawler Crawler
+	Market      types.Market
+	Pair        Pair
+}
+
+// Creates a new crawler for a market
+func NewBitstampCrawler(market types.Market) (BitstampCrawler, error) {
+	pair, err := BitstampMarkets.Resolve(BITSTAMP_MODULE_NAME, market)
+	if err != nil {
+		return BitstampCrawler{}, err
+	}
+
+	return BitstampCrawler{
+		DataCrawler: NewCrawler(fmt.Sprintf(BITSTAMP_APIURL, pair.Symbol)),
+		Market:      market,
+		Pair:        pair,
+	}, nil
+}
+
+// Return the name of this crawler
+func (c BitstampCrawler) GetName() string {
+	return BITSTAMP_MODULE_NAME
+}
+
+func (c BitstampCrawler) GetTicker() string {
+	return c.Pair.Symbol
+}
+
+func (c BitstampCrawler) GetMarket() types.Market {
+	return c.Market
+}
+
+// Serializes a json to a QuotePriceInfo type
+func (c BitstampCrawler) ToQuotePriceInfo(jsonData []byte) (types.QuotePriceInfo, error) {
+
+	var result types.QuotePriceInfo
+	aux := struct {
+		Volume    string `json:"volume"`
+		VWAP      string `json:"vwap"`
+		HighPrice string `json:"high"`
+		LastPrice string `json:"last"`
+		OpenPrice string `json:"open"`
+	}{}
+
+	if err := json.Unmarshal(jsonData, &aux); err != nil {
+		return result, err
+	}
+
+	var vwap float64
+	var err error
+	result = types.QuotePriceInfo{}
+	// All the numbers are strings. The last price is the one used by the index, so it must be valid
+	if result.LastPrice, err = strconv.ParseFloat(aux.LastPrice, 64); err != nil {
+		return result, fmt.Errorf("invalid last price %q", aux.LastPrice)
+	}
+	result.Volume, _ = strconv.ParseFloat(aux.Volume, 64)
+	result.HighPrice, _ = strconv.ParseFloat(aux.HighPrice, 64)
+	result.OpenPrice, _ = strconv.ParseFloat(aux.OpenPrice, 64)
+	vwap, _ = strconv.ParseFloat(aux.VWAP, 64)
+	result.QuoteVolume = result.Volume * vwap
+	result.QuoteCurrency = c.Pair.Quote
+
+	return result, nil
+}
+
+// Helper function to convert the json from Bitstamp´s API to a QuotePriceInfo instance
+func (c BitstampCrawler) Crawl(ctx context.Context) (types.QuotePriceInfo, error) {
+
+	jsonData, err := c.DataCrawler.Get(ctx)
+	if err != nil {
+		return types.QuotePriceInfo{}, err
+	}
+
+	priceInfo, err := c.ToQuotePriceInfo(jsonData)
+	if err != nil {
+		return priceInfo, err
+	}
+	priceInfo.Timestamp = time.Now().Unix()
+	priceInfo.DataURL = c.DataCrawler.Url
+
+	return priceInfo, nil
+}
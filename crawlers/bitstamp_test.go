@@ -0,0 +1,67 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"testing"
+)
+
+func TestBitstampToQuotePriceInfo(t *testing.T) {
+
+	crawler, err := NewBitstampCrawler(parseMarket(t, "BTC/USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := crawler.ToQuotePriceInfo(readSample(t, "bitstamp_ticker.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.LastPrice != 8750.72 || info.OpenPrice != 9196.39 || info.HighPrice != 9275 || info.Volume != 8237.68395373 {
+		t.Errorf("unexpected prices or volume: %+v", info)
+	}
+	// Bitstamp doesn´t publish the quote volume: it is the volume at the VWAP of the day
+	if info.QuoteVolume != info.Volume*8930.29 {
+		t.Errorf("QuoteVolume = %v, want the volume at the VWAP %v", info.QuoteVolume, info.Volume*8930.29)
+	}
+}
+
+// Bitstamp sends all the numbers as strings, with 8 decimals
+func TestBitstampStringFields(t *testing.T) {
+
+	crawler, err := NewBitstampCrawler(parseMarket(t, "BTC/USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		ticker  string
+		wantErr bool
+		last    float64
+		volume  float64
+	}{
+		{name: "trailing zeros", ticker: `{"last": "8750.72000000", "volume": "1.00000000", "vwap": "8750.00000000"}`, last: 8750.72, volume: 1},
+		{name: "integers", ticker: `{"last": "8750", "volume": "2", "vwap": "8750"}`, last: 8750, volume: 2},
+		{name: "numbers instead of strings", ticker: `{"last": 8750.72, "volume": "1", "vwap": "8750"}`, wantErr: true},
+		{name: "not a number", ticker: `{"last": "n/a", "volume": "1", "vwap": "8750"}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := crawler.ToQuotePriceInfo([]byte(test.ticker))
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.LastPrice != test.last || info.Volume != test.volume {
+				t.Errorf("last price %v and volume %v, want %v and %v", info.LastPrice, info.Volume, test.last, test.volume)
+			}
+		})
+	}
+}
@@ -0,0 +1,121 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+const (
+	COINBASE_MODULE_NAME   = "Coinbase REST API"
+	COINBASE_TICKER_APIURL = "https://api.exchange.coinbase.com/products/%s/ticker"
+	COINBASE_STATS_APIURL  = "https://api.exchange.coinbase.com/products/%s/stats"
+)
+
+// CoinbaseMarkets are the products available in Coinbase
+var CoinbaseMarkets = Markets{
+	"BTCUSD": {Symbol: "BTC-USD"},
+	"ETHUSD": {Symbol: "ETH-USD"},
+	"BTCEUR": {Symbol: "BTC-EUR"},
+	"ETHEUR": {Symbol: "ETH-EUR"},
+	"BTCGBP": {Symbol: "BTC-GBP"},
+	"ETHBTC": {Symbol: "ETH-BTC"},
+}
+
+// The REST API client to get data from Coinbase Exchange (formerly Coinbase Pro). The price is taken from the ticker
+// and the 24h values from the stats of the product
+type CoinbaseCrawler struct {
+	DataCrawler  Crawler
+	StatsCrawler Crawler
+	Market       types.Market
+	Pair         Pair
+}
+
+// Creates a new crawler for a market
+func NewCoinbaseCrawler(market types.Market) (CoinbaseCrawler, error) {
+	pair, err := CoinbaseMarkets.Resolve(COINBASE_MODULE_NAME, market)
+	if err != nil {
+		return CoinbaseCrawler{}, err
+	}
+
+	return CoinbaseCrawler{
+		DataCrawler:  NewCrawler(fmt.Sprintf(COINBASE_TICKER_APIURL, pair.Symbol)),
+		StatsCrawler: NewCrawler(fmt.Sprintf(COINBASE_STATS_APIURL, pair.Symbol)),
+		Market:       market,
+		Pair:         pair,
+	}, nil
+}
+
+// Return the name of this crawler
+func (c CoinbaseCrawler) GetName() string {
+	return COINBASE_MODULE_NAME
+}
+
+func (c CoinbaseCrawler) GetTicker() string {
+	return c.Pair.Symbol
+}
+
+func (c CoinbaseCrawler) GetMarket() types.Market {
+	return c.Market
+}
+
+// Serializes the json of the ticker and the stats of a product to a QuotePriceInfo type
+func (c CoinbaseCrawler) ToQuotePriceInfo(tickerData []byte, statsData []byte) (types.QuotePriceInfo, error) {
+
+	var result types.QuotePriceInfo
+	ticker := struct {
+		Price string `json:"price"`
+	}{}
+	stats := struct {
+		Open   string `json:"open"`
+		High   string `json:"high"`
+		Volume string `json:"volume"`
+	}{}
+
+	if err := json.Unmarshal(tickerData, &ticker); err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(statsData, &stats); err != nil {
+		return result, err
+	}
+
+	result = types.QuotePriceInfo{}
+	result.LastPrice, _ = strconv.ParseFloat(ticker.Price, 64)
+	result.Volume, _ = strconv.ParseFloat(stats.Volume, 64)
+	result.HighPrice, _ = strconv.ParseFloat(stats.High, 64)
+	result.OpenPrice, _ = strconv.ParseFloat(stats.Open, 64)
+	// Coinbase doesn´t publish the quote volume, so it is estimated with the latest price
+	result.QuoteVolume = result.Volume * result.LastPrice
+	result.QuoteCurrency = c.Pair.Quote
+
+	return result, nil
+}
+
+// Helper function to convert the json from Coinbase´s API to a QuotePriceInfo instance
+func (c CoinbaseCrawler) Crawl(ctx context.Context) (types.QuotePriceInfo, error) {
+
+	tickerData, err := c.DataCrawler.Get(ctx)
+	if err != nil {
+		return types.QuotePriceInfo{}, err
+	}
+	statsData, err := c.StatsCrawler.Get(ctx)
+	if err != nil {
+		return types.QuotePriceInfo{}, err
+	}
+
+	priceInfo, err := c.ToQuotePriceInfo(tickerData, statsData)
+	if err != nil {
+		return priceInfo, err
+	}
+	priceInfo.Timestamp = time.Now().Unix()
+	priceInfo.DataURL = c.DataCrawler.Url
+
+	return priceInfo, nil
+}
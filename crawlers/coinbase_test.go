@@ -0,0 +1,90 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// Reads a sample answer of an exchange API
+func readSample(t *testing.T, name string) []byte {
+	t.Helper()
+
+	data, err := ioutil.ReadFile("samples/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// Parses a market of the tests
+func parseMarket(t *testing.T, text string) types.Market {
+	t.Helper()
+
+	market, err := types.ParseMarket(text)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return market
+}
+
+func TestCoinbaseToQuotePriceInfo(t *testing.T) {
+
+	crawler, err := NewCoinbaseCrawler(parseMarket(t, "BTC/USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticker := readSample(t, "coinbase_ticker.json")
+	stats := readSample(t, "coinbase_stats.json")
+
+	tests := []struct {
+		name    string
+		ticker  []byte
+		stats   []byte
+		wantErr bool
+		last    float64
+		open    float64
+		high    float64
+		volume  float64
+	}{
+		{name: "samples", ticker: ticker, stats: stats, last: 8749.73, open: 9205.01, high: 9250, volume: 9628.44316591},
+		{name: "invalid ticker", ticker: []byte("{"), stats: stats, wantErr: true},
+		{name: "invalid stats", ticker: ticker, stats: []byte("[]"), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := crawler.ToQuotePriceInfo(test.ticker, test.stats)
+			if test.wantErr {
+				if err == nil {
+					t.Fatal("expected an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.LastPrice != test.last {
+				t.Errorf("LastPrice = %v, want %v", info.LastPrice, test.last)
+			}
+			if info.OpenPrice != test.open {
+				t.Errorf("OpenPrice = %v, want %v", info.OpenPrice, test.open)
+			}
+			if info.HighPrice != test.high {
+				t.Errorf("HighPrice = %v, want %v", info.HighPrice, test.high)
+			}
+			if info.Volume != test.volume {
+				t.Errorf("Volume = %v, want %v", info.Volume, test.volume)
+			}
+			// The quote volume is estimated with the latest price
+			if info.QuoteVolume != test.volume*test.last {
+				t.Errorf("QuoteVolume = %v, want %v", info.QuoteVolume, test.volume*test.last)
+			}
+		})
+	}
+}
@@ -4,6 +4,8 @@
 package crawlers
 
 import (
+	"context"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 )
@@ -20,11 +22,11 @@ func NewCrawler(url string) Crawler {
 	}
 }
 
-// Return the data. For now, it is just a GET
-func (crawler Crawler) Get() ([]byte, error) {
+// Return the data. For now, it is just a GET. The request is cancelled when the context is done
+func (crawler Crawler) Get(ctx context.Context) ([]byte, error) {
 
 	client := &http.Client{}
-	req, err := http.NewRequest("GET", crawler.Url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", crawler.Url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -44,8 +46,12 @@ func (crawler Crawler) Get() ([]byte, error) {
 	response, err := client.Do(req)
 	if err != nil {
 		return nil, err
-	} else {
-		data, _ := ioutil.ReadAll(response.Body)
-		return data, nil
 	}
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected response from %s: %s", crawler.Url, response.Status)
+	}
+
+	return ioutil.ReadAll(response.Body)
 }
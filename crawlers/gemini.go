@@ -0,0 +1,127 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+const (
+	GEMINI_MODULE_NAME   = "Gemini REST API"
+	GEMINI_APIURL        = "https://api.gemini.com/v1/pubticker/%s"
+	GEMINI_TICKER_APIURL = "https://api.gemini.com/v2/ticker/%s"
+)
+
+// GeminiMarkets are the markets available in Gemini
+var GeminiMarkets = Markets{
+	"BTCUSD": {Symbol: "btcusd"},
+	"ETHUSD": {Symbol: "ethusd"},
+	"BTCEUR": {Symbol: "btceur"},
+	"BTCGBP": {Symbol: "btcgbp"},
+	"ETHBTC": {Symbol: "ethbtc"},
+}
+
+// The REST API client to get data from Gemini. The price and volumes are taken from the public ticker (v1), and
+// the open and high prices from the ticker of the v2 API
+type GeminiCrawler struct {
+	DataCrawler   Crawler
+	TickerCrawler Crawler
+	Market        types.Market
+	Pair          Pair
+}
+
+// Creates a new crawler for a market
+func NewGeminiCrawler(market types.Market) (GeminiCrawler, error) {
+	pair, err := GeminiMarkets.Resolve(GEMINI_MODULE_NAME, market)
+	if err != nil {
+		return GeminiCrawler{}, err
+	}
+
+	return GeminiCrawler{
+		DataCrawler:   NewCrawler(fmt.Sprintf(GEMINI_APIURL, pair.Symbol)),
+		TickerCrawler: NewCrawler(fmt.Sprintf(GEMINI_TICKER_APIURL, pair.Symbol)),
+		Market:        market,
+		Pair:          pair,
+	}, nil
+}
+
+// Return the name of this crawler
+func (c GeminiCrawler) GetName() string {
+	return GEMINI_MODULE_NAME
+}
+
+func (c GeminiCrawler) GetTicker() string {
+	return c.Pair.Symbol
+}
+
+func (c GeminiCrawler) GetMarket() types.Market {
+	return c.Market
+}
+
+// Serializes the json of the public ticker (v1) and the ticker (v2) to a QuotePriceInfo type
+func (c GeminiCrawler) ToQuotePriceInfo(pubTickerData []byte, tickerData []byte) (types.QuotePriceInfo, error) {
+
+	var result types.QuotePriceInfo
+	pubTicker := struct {
+		LastPrice string                     `json:"last"`
+		Volume    map[string]json.RawMessage `json:"volume"` // Indexed by asset, plus a timestamp
+	}{}
+	ticker := struct {
+		OpenPrice string `json:"open"`
+		HighPrice string `json:"high"`
+	}{}
+
+	if err := json.Unmarshal(pubTickerData, &pubTicker); err != nil {
+		return result, err
+	}
+	if err := json.Unmarshal(tickerData, &ticker); err != nil {
+		return result, err
+	}
+
+	var volume, quoteVolume string
+	if err := json.Unmarshal(pubTicker.Volume[c.Market.Base], &volume); err != nil {
+		return result, fmt.Errorf("invalid volume of %s: %v", c.Market.Base, err)
+	}
+	if err := json.Unmarshal(pubTicker.Volume[c.Pair.Quote], &quoteVolume); err != nil {
+		return result, fmt.Errorf("invalid volume of %s: %v", c.Pair.Quote, err)
+	}
+
+	result = types.QuotePriceInfo{}
+	result.LastPrice, _ = strconv.ParseFloat(pubTicker.LastPrice, 64)
+	result.Volume, _ = strconv.ParseFloat(volume, 64)
+	result.QuoteVolume, _ = strconv.ParseFloat(quoteVolume, 64)
+	result.OpenPrice, _ = strconv.ParseFloat(ticker.OpenPrice, 64)
+	result.HighPrice, _ = strconv.ParseFloat(ticker.HighPrice, 64)
+	result.QuoteCurrency = c.Pair.Quote
+
+	return result, nil
+}
+
+// Helper function to convert the json from Gemini´s API to a QuotePriceInfo instance
+func (c GeminiCrawler) Crawl(ctx context.Context) (types.QuotePriceInfo, error) {
+
+	pubTickerData, err := c.DataCrawler.Get(ctx)
+	if err != nil {
+		return types.QuotePriceInfo{}, err
+	}
+	tickerData, err := c.TickerCrawler.Get(ctx)
+	if err != nil {
+		return types.QuotePriceInfo{}, err
+	}
+
+	priceInfo, err := c.ToQuotePriceInfo(pubTickerData, tickerData)
+	if err != nil {
+		return priceInfo, err
+	}
+	priceInfo.Timestamp = time.Now().Unix()
+	priceInfo.DataURL = c.DataCrawler.Url
+
+	return priceInfo, nil
+}
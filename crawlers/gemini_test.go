@@ -0,0 +1,72 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"testing"
+)
+
+func TestGeminiToQuotePriceInfo(t *testing.T) {
+
+	crawler, err := NewGeminiCrawler(parseMarket(t, "BTC/USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := crawler.ToQuotePriceInfo(readSample(t, "gemini_pubticker.json"), readSample(t, "gemini_ticker_v2.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The price and the volumes come from the public ticker, the open and high prices from the v2 ticker
+	if info.LastPrice != 8749.83 || info.Volume != 1722.5383421966 || info.QuoteVolume != 15318389.563612587 {
+		t.Errorf("last price %v, volume %v and quote volume %v don´t match the public ticker", info.LastPrice, info.Volume, info.QuoteVolume)
+	}
+	if info.OpenPrice != 9194.38 || info.HighPrice != 9261.74 {
+		t.Errorf("open price %v and high price %v don´t match the v2 ticker", info.OpenPrice, info.HighPrice)
+	}
+}
+
+// The volumes of the public ticker are a map indexed by asset, with the timestamp of the volumes as a number
+func TestGeminiVolumeMap(t *testing.T) {
+
+	crawler, err := NewGeminiCrawler(parseMarket(t, "BTC/USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticker := readSample(t, "gemini_ticker_v2.json")
+
+	tests := []struct {
+		name        string
+		volume      string
+		wantErr     bool
+		baseVolume  float64
+		quoteVolume float64
+	}{
+		{name: "assets in any order", volume: `{"timestamp": 1573258200000, "USD": "875.5", "BTC": "0.1"}`, baseVolume: 0.1, quoteVolume: 875.5},
+		{name: "other assets", volume: `{"BTC": "0.1", "ETH": "2", "USD": "875.5"}`, baseVolume: 0.1, quoteVolume: 875.5},
+		{name: "missing base asset", volume: `{"USD": "875.5", "timestamp": 1573258200000}`, wantErr: true},
+		{name: "missing quote asset", volume: `{"BTC": "0.1", "timestamp": 1573258200000}`, wantErr: true},
+		{name: "volume as a number", volume: `{"BTC": 0.1, "USD": "875.5"}`, wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			pubTicker := []byte(`{"last": "8749.83", "volume": ` + test.volume + `}`)
+
+			info, err := crawler.ToQuotePriceInfo(pubTicker, ticker)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.Volume != test.baseVolume || info.QuoteVolume != test.quoteVolume {
+				t.Errorf("volumes %v and %v, want %v and %v", info.Volume, info.QuoteVolume, test.baseVolume, test.quoteVolume)
+			}
+		})
+	}
+}
@@ -0,0 +1,158 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+const (
+	KRAKEN_MODULE_NAME = "Kraken REST API"
+	KRAKEN_APIURL      = "https://api.kraken.com/0/public/Ticker?pair=%s"
+)
+
+// KrakenSymbols translates the Kraken´s asset codes. The legacy assets are prefixed with X (crypto) or Z (fiat)
+// in the answers, i.e. XXBTZUSD for BTCUSD
+var KrakenSymbols = NewSymbolTable(
+	map[string]string{
+		"BTC":  "XBT",
+		"DOGE": "XDG",
+	},
+	map[string]string{
+		"XXBT": "BTC",
+		"XXDG": "DOGE",
+		"XETH": "ETH", "ETH": "ETH",
+		"XLTC": "LTC", "LTC": "LTC",
+		"XXRP": "XRP", "XRP": "XRP",
+		"ZUSD": "USD", "USD": "USD",
+		"ZEUR": "EUR", "EUR": "EUR",
+		"ZGBP": "GBP", "GBP": "GBP",
+		"ZJPY": "JPY", "JPY": "JPY",
+		"ZCAD": "CAD", "CAD": "CAD",
+		"USDT": "USDT",
+	},
+)
+
+// KrakenMarkets are the markets available in Kraken, with the pairs built from the Kraken´s asset codes
+var KrakenMarkets = Markets{
+	"BTCUSD": krakenPair("BTC", "USD"),
+	"ETHUSD": krakenPair("ETH", "USD"),
+	"BTCEUR": krakenPair("BTC", "EUR"),
+	"ETHEUR": krakenPair("ETH", "EUR"),
+	"BTCGBP": krakenPair("BTC", "GBP"),
+	"BTCJPY": krakenPair("BTC", "JPY"),
+	"ETHBTC": krakenPair("ETH", "BTC"),
+}
+
+// Returns the pair for the Kraken´s API, like XBTUSD
+func krakenPair(base string, quote string) Pair {
+	return Pair{Symbol: KrakenSymbols.VenueAsset(base) + KrakenSymbols.VenueAsset(quote)}
+}
+
+// The REST API client to get data from Kraken
+type KrakenCrawler struct {
+	DataCrawler Crawler
+	Market      types.Market
+	Pair        Pair
+}
+
+// Creates a new crawler for a market
+func NewKrakenCrawler(market types.Market) (KrakenCrawler, error) {
+	pair, err := KrakenMarkets.Resolve(KRAKEN_MODULE_NAME, market)
+	if err != nil {
+		return KrakenCrawler{}, err
+	}
+
+	return KrakenCrawler{
+		DataCrawler: NewCrawler(fmt.Sprintf(KRAKEN_APIURL, pair.Symbol)),
+		Market:      market,
+		Pair:        pair,
+	}, nil
+}
+
+// Return the name of this crawler
+func (c KrakenCrawler) GetName() string {
+	return KRAKEN_MODULE_NAME
+}
+
+func (c KrakenCrawler) GetTicker() string {
+	return c.Pair.Symbol
+}
+
+func (c KrakenCrawler) GetMarket() types.Market {
+	return c.Market
+}
+
+// Serializes a json to a QuotePriceInfo type. Each value of the ticker is an array, where the 24h value is
+// the latest one
+func (c KrakenCrawler) ToQuotePriceInfo(jsonData []byte) (types.QuotePriceInfo, error) {
+
+	var result types.QuotePriceInfo
+	type tickerInfo struct {
+		LastTrade []string `json:"c"` // [price, lot volume]
+		Volume    []string `json:"v"` // [today, last 24h]
+		VWAP      []string `json:"p"` // [today, last 24h]
+		High      []string `json:"h"` // [today, last 24h]
+		Open      string   `json:"o"`
+	}
+	aux := struct {
+		Error  []string              `json:"error"`
+		Result map[string]tickerInfo `json:"result"`
+	}{}
+
+	if err := json.Unmarshal(jsonData, &aux); err != nil {
+		return result, err
+	}
+	if len(aux.Error) > 0 {
+		return result, fmt.Errorf("error from Kraken: %s", strings.Join(aux.Error, ", "))
+	}
+
+	for pair, info := range aux.Result {
+		base, quote, ok := KrakenSymbols.SplitPair(pair)
+		if !ok || base != c.Market.Base || quote != c.Pair.Quote {
+			return result, fmt.Errorf("unexpected pair %s from Kraken, expected %s", pair, c.Pair.Symbol)
+		}
+		if len(info.LastTrade) < 1 || len(info.Volume) < 2 || len(info.VWAP) < 2 || len(info.High) < 2 {
+			return result, fmt.Errorf("unexpected ticker format from Kraken for %s", pair)
+		}
+
+		var vwap float64
+		result.LastPrice, _ = strconv.ParseFloat(info.LastTrade[0], 64)
+		result.Volume, _ = strconv.ParseFloat(info.Volume[1], 64)
+		result.HighPrice, _ = strconv.ParseFloat(info.High[1], 64)
+		result.OpenPrice, _ = strconv.ParseFloat(info.Open, 64)
+		vwap, _ = strconv.ParseFloat(info.VWAP[1], 64)
+		result.QuoteVolume = result.Volume * vwap
+		result.QuoteCurrency = quote
+
+		return result, nil
+	}
+
+	return result, fmt.Errorf("there is no ticker for %s in the answer from Kraken", c.Pair.Symbol)
+}
+
+// Helper function to convert the json from Kraken´s API to a QuotePriceInfo instance
+func (c KrakenCrawler) Crawl(ctx context.Context) (types.QuotePriceInfo, error) {
+
+	jsonData, err := c.DataCrawler.Get(ctx)
+	if err != nil {
+		return types.QuotePriceInfo{}, err
+	}
+
+	priceInfo, err := c.ToQuotePriceInfo(jsonData)
+	if err != nil {
+		return priceInfo, err
+	}
+	priceInfo.Timestamp = time.Now().Unix()
+	priceInfo.DataURL = c.DataCrawler.Url
+
+	return priceInfo, nil
+}
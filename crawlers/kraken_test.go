@@ -0,0 +1,84 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"testing"
+)
+
+func TestKrakenToQuotePriceInfo(t *testing.T) {
+
+	crawler, err := NewKrakenCrawler(parseMarket(t, "BTC/USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crawler.GetTicker() != "XBTUSD" {
+		t.Errorf("GetTicker() = %s, want XBTUSD", crawler.GetTicker())
+	}
+
+	tests := []struct {
+		name    string
+		ticker  []byte
+		wantErr bool
+	}{
+		{name: "sample", ticker: readSample(t, "kraken_ticker.json")},
+		{name: "error", ticker: []byte(`{"error": ["EQuery:Unknown asset pair"]}`), wantErr: true},
+		{name: "other pair", ticker: []byte(`{"error": [], "result": {"XETHZUSD": {"c": ["180.1", "1"], "v": ["1", "2"], "p": ["180", "181"], "h": ["182", "183"], "o": "179"}}}`), wantErr: true},
+		{name: "short arrays", ticker: []byte(`{"error": [], "result": {"XXBTZUSD": {"c": ["8751.4", "1"], "v": ["1"], "p": ["8766"], "h": ["8867"], "o": "8805.9"}}}`), wantErr: true},
+		{name: "empty result", ticker: []byte(`{"error": [], "result": {}}`), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := crawler.ToQuotePriceInfo(test.ticker)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			// The 24h values are the second ones of the arrays
+			if info.LastPrice != 8751.4 || info.OpenPrice != 8805.9 || info.HighPrice != 9257 || info.Volume != 4329.79519218 {
+				t.Errorf("unexpected prices or volume: %+v", info)
+			}
+			if info.QuoteVolume != info.Volume*8901.83484 {
+				t.Errorf("QuoteVolume = %v, want the volume at the VWAP %v", info.QuoteVolume, info.Volume*8901.83484)
+			}
+			if info.QuoteCurrency != "USD" {
+				t.Errorf("QuoteCurrency = %q, want USD", info.QuoteCurrency)
+			}
+		})
+	}
+}
+
+func TestKrakenSplitPair(t *testing.T) {
+
+	tests := []struct {
+		pair  string
+		base  string
+		quote string
+		ok    bool
+	}{
+		{pair: "XXBTZUSD", base: "BTC", quote: "USD", ok: true},
+		{pair: "XETHXXBT", base: "ETH", quote: "BTC", ok: true},
+		{pair: "XXDGZEUR", base: "DOGE", quote: "EUR", ok: true},
+		{pair: "XBTUSD", base: "BTC", quote: "USD", ok: true},
+		{pair: "ETHXBT", base: "ETH", quote: "BTC", ok: true},
+		{pair: "XBTUSDT", base: "BTC", quote: "USDT", ok: true},
+		{pair: "XXBTZ", ok: false},
+		{pair: "FOOBAR", ok: false},
+		{pair: "", ok: false},
+	}
+
+	for _, test := range tests {
+		base, quote, ok := KrakenSymbols.SplitPair(test.pair)
+		if ok != test.ok || base != test.base || quote != test.quote {
+			t.Errorf("SplitPair(%q) = %q, %q, %v, want %q, %q, %v", test.pair, base, quote, ok, test.base, test.quote, test.ok)
+		}
+	}
+}
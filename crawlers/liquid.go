@@ -4,7 +4,9 @@
 package crawlers
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"strconv"
 	"time"
 
@@ -13,67 +15,100 @@ import (
 
 const (
 	LIQUID_MODULE_NAME = "Liquid REST API"
-	LIQUID_APIURL      = "https://api.liquid.com/products/1"
+	LIQUID_APIURL      = "https://api.liquid.com/products/%s"
 )
 
+// LiquidMarkets are the markets available in Liquid. The products are requested by their id
+var LiquidMarkets = Markets{
+	"BTCUSD": {Symbol: "1"},
+	"BTCJPY": {Symbol: "5"},
+}
+
 // The REST API client to get data from Liquid
 type LiquidCrawler struct {
 	DataCrawler Crawler
-	Ticker      string
+	Market      types.Market
+	Pair        Pair
 }
 
-// Creates a new crawler
-func NewLiquidCrawler() LiquidCrawler {
-	crawler := NewCrawler(LIQUID_APIURL)
+// Creates a new crawler for a market
+func NewLiquidCrawler(market types.Market) (LiquidCrawler, error) {
+	pair, err := LiquidMarkets.Resolve(LIQUID_MODULE_NAME, market)
+	if err != nil {
+		return LiquidCrawler{}, err
+	}
+
+	crawler := NewCrawler(fmt.Sprintf(LIQUID_APIURL, pair.Symbol))
 	crawler.Headers = make(map[string]string)
 	crawler.Headers["X-Quoine-API-Version"] = "2"
 
 	return LiquidCrawler{
 		DataCrawler: crawler,
-	}
+		Market:      market,
+		Pair:        pair,
+	}, nil
 }
 
 // Return the name of this crawler
 func (c LiquidCrawler) GetName() string {
-	return c.Ticker
+	return LIQUID_MODULE_NAME
 }
 
 func (c LiquidCrawler) GetTicker() string {
-	return c.Ticker
+	return c.Pair.Symbol
+}
+
+func (c LiquidCrawler) GetMarket() types.Market {
+	return c.Market
 }
 
 // Serializes a json to a TickerInfo24 type
-func (c LiquidCrawler) ToQuotePriceInfo(jsonData []byte) types.QuotePriceInfo {
+func (c LiquidCrawler) ToQuotePriceInfo(jsonData []byte) (types.QuotePriceInfo, error) {
 
 	var result types.QuotePriceInfo
 	aux := struct {
+		PairCode  string `json:"currency_pair_code"`
 		Volume    string `json:"volume_24h"`
 		HighPrice string `json:"high_market_ask"`
+		LastPrice string `json:"last_traded_price"`
 	}{}
 
 	if err := json.Unmarshal(jsonData, &aux); err != nil {
-		panic(err)
+		return result, err
+	}
+	if aux.PairCode != c.Market.Ticker() {
+		return result, fmt.Errorf("unexpected product %s from Liquid, expected %s", aux.PairCode, c.Market.Ticker())
 	}
 
+	var err error
 	result = types.QuotePriceInfo{}
+	// The last price is the one used by the index, so it must be valid
+	if result.LastPrice, err = strconv.ParseFloat(aux.LastPrice, 64); err != nil {
+		return result, fmt.Errorf("invalid last price %q", aux.LastPrice)
+	}
 	result.Volume, _ = strconv.ParseFloat(aux.Volume, 32)
 	// result.QuoteVolume, _ = strconv.ParseFloat(aux.QuoteVolume, 32)
 	result.HighPrice, _ = strconv.ParseFloat(aux.HighPrice, 32)
+	result.QuoteCurrency = c.Pair.Quote
 	// result.OpenPrice, _ = strconv.ParseFloat(aux.OpenPrice, 32)
 
-	return result
+	return result, nil
 }
 
 // Helper function to convert the json from Liquid´s API to a QuotePriceInfo instance
-func (c LiquidCrawler) Crawl(quotedCurrency string, done chan types.QuotePriceInfo) {
+func (c LiquidCrawler) Crawl(ctx context.Context) (types.QuotePriceInfo, error) {
 
-	jsonData, err := c.DataCrawler.Get()
+	jsonData, err := c.DataCrawler.Get(ctx)
 	if err != nil {
-		return
+		return types.QuotePriceInfo{}, err
 	}
 
-	priceInfo := c.ToQuotePriceInfo(jsonData)
+	priceInfo, err := c.ToQuotePriceInfo(jsonData)
+	if err != nil {
+		return priceInfo, err
+	}
 	priceInfo.Timestamp = time.Now().Unix()
-	priceInfo.DataURL = LIQUID_APIURL
-	done <- priceInfo
+	priceInfo.DataURL = c.DataCrawler.Url
+
+	return priceInfo, nil
 }
@@ -0,0 +1,46 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"testing"
+)
+
+func TestLiquidToQuotePriceInfo(t *testing.T) {
+
+	crawler, err := NewLiquidCrawler(parseMarket(t, "BTC/USD"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		ticker  []byte
+		wantErr bool
+		last    float64
+	}{
+		{name: "sample", ticker: readSample(t, "liquid_product_1.json"), last: 8750},
+		// A 32 bits float would round the price to 8751.669921875
+		{name: "decimals", ticker: []byte(`{"currency_pair_code": "BTCUSD", "last_traded_price": "8751.66952"}`), last: 8751.66952},
+		{name: "invalid last price", ticker: []byte(`{"currency_pair_code": "BTCUSD", "last_traded_price": null}`), wantErr: true},
+		{name: "other product", ticker: []byte(`{"currency_pair_code": "ETHUSD", "last_traded_price": "180.5"}`), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			info, err := crawler.ToQuotePriceInfo(test.ticker)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if info.LastPrice != test.last {
+				t.Errorf("LastPrice = %v, want %v", info.LastPrice, test.last)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"log"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// Exchange is the entry of a venue in the registry of crawlers
+type Exchange struct {
+	Name    string
+	Markets Markets
+	New     func(market types.Market) (types.PriceEvidenceCrawler, error)
+}
+
+// Exchanges is the registry of all the venues with a crawler
+var Exchanges = []Exchange{
+	{BINANCE_MODULE_NAME, BinanceMarkets, func(m types.Market) (types.PriceEvidenceCrawler, error) { return NewBinanceCrawler(m) }},
+	{BITFINEX_MODULE_NAME, BitfinexMarkets, func(m types.Market) (types.PriceEvidenceCrawler, error) { return NewBitfinexCrawler(m) }},
+	{BITFLYER_MODULE_NAME, BitflyerMarkets, func(m types.Market) (types.PriceEvidenceCrawler, error) { return NewBitflyerCrawler(m) }},
+	{BITSTAMP_MODULE_NAME, BitstampMarkets, func(m types.Market) (types.PriceEvidenceCrawler, error) { return NewBitstampCrawler(m) }},
+	{COINBASE_MODULE_NAME, CoinbaseMarkets, func(m types.Market) (types.PriceEvidenceCrawler, error) { return NewCoinbaseCrawler(m) }},
+	{GEMINI_MODULE_NAME, GeminiMarkets, func(m types.Market) (types.PriceEvidenceCrawler, error) { return NewGeminiCrawler(m) }},
+	{KRAKEN_MODULE_NAME, KrakenMarkets, func(m types.Market) (types.PriceEvidenceCrawler, error) { return NewKrakenCrawler(m) }},
+	{LIQUID_MODULE_NAME, LiquidMarkets, func(m types.Market) (types.PriceEvidenceCrawler, error) { return NewLiquidCrawler(m) }},
+	{UPBIT_MODULE_NAME, UpBitMarkets, func(m types.Market) (types.PriceEvidenceCrawler, error) { return NewUpBitCrawler(m) }},
+}
+
+// NewCrawlers returns a crawler for the market from each venue where the market is available
+func NewCrawlers(market types.Market) []types.PriceEvidenceCrawler {
+	var directory []types.PriceEvidenceCrawler
+
+	for _, exchange := range Exchanges {
+		if _, ok := exchange.Markets[market.Ticker()]; !ok {
+			continue
+		}
+
+		crawler, err := exchange.New(market)
+		if err != nil {
+			log.Printf("Unable to create the crawler of %s for %s: %v", exchange.Name, market, err)
+			continue
+		}
+		directory = append(directory, crawler)
+	}
+
+	return directory
+}
@@ -0,0 +1,84 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"fmt"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// Pair is the code of a market in a venue
+type Pair struct {
+	Symbol string // Code of the pair in the venue (BTCUSDT, tBTCUSD, XBTUSD...)
+	Quote  string // Currency quoted by the venue, when it is not the quote of the market (USDT for USD in Binance)
+}
+
+// Markets is the registry of the markets supported by a venue, indexed by the ticker of the market (BTCUSD)
+type Markets map[string]Pair
+
+// Resolve returns the pair used by a venue for a market. The quote of the pair is always filled
+func (m Markets) Resolve(venue string, market types.Market) (Pair, error) {
+	pair, ok := m[market.Ticker()]
+	if !ok {
+		return pair, fmt.Errorf("the market %s is not available in %s", market, venue)
+	}
+	if pair.Quote == "" {
+		pair.Quote = market.Quote
+	}
+
+	return pair, nil
+}
+
+// SymbolTable translates the asset codes used in DarkMatter (BTC, USD, ...) to the codes used by a venue, and back
+type SymbolTable struct {
+	toVenue   map[string]string
+	fromVenue map[string]string
+}
+
+// NewSymbolTable creates a table from the codes of the venue for each asset. Aliases is a list of other codes
+// used by the venue for the same assets (i.e. in the answers), indexed by the venue´s code
+func NewSymbolTable(assets map[string]string, aliases map[string]string) SymbolTable {
+	table := SymbolTable{
+		toVenue:   make(map[string]string),
+		fromVenue: make(map[string]string),
+	}
+
+	for asset, code := range assets {
+		table.toVenue[asset] = code
+		table.fromVenue[code] = asset
+	}
+	for alias, asset := range aliases {
+		table.fromVenue[alias] = asset
+	}
+
+	return table
+}
+
+// VenueAsset returns the code of the asset in the venue. The assets without translation keep their code
+func (t SymbolTable) VenueAsset(asset string) string {
+	if code, ok := t.toVenue[asset]; ok {
+		return code
+	}
+	return asset
+}
+
+// Asset returns the DarkMatter code of an asset from the code used by the venue
+func (t SymbolTable) Asset(code string) (string, bool) {
+	asset, ok := t.fromVenue[code]
+	return asset, ok
+}
+
+// SplitPair returns the base and quote assets of a pair code from the venue, like XXBTZUSD or XBTUSD
+func (t SymbolTable) SplitPair(pair string) (string, string, bool) {
+	for i := 1; i < len(pair); i++ {
+		base, baseOk := t.Asset(pair[:i])
+		quote, quoteOk := t.Asset(pair[i:])
+		if baseOk && quoteOk {
+			return base, quote, true
+		}
+	}
+
+	return "", "", false
+}
@@ -0,0 +1,113 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+const (
+	UPBIT_MODULE_NAME = "UpBit REST API"
+	UPBIT_APIURL      = "https://api.upbit.com/v1/ticker?markets=%s"
+)
+
+// UpBitMarkets are the markets available in UpBit. The codes of the markets start with the quote currency
+var UpBitMarkets = Markets{
+	"BTCKRW": {Symbol: "KRW-BTC"},
+	"ETHKRW": {Symbol: "KRW-ETH"},
+	"ETHBTC": {Symbol: "BTC-ETH"},
+}
+
+// The REST API client to get data from UpBit
+type UpBitCrawler struct {
+	DataCrawler Crawler
+	Market      types.Market
+	Pair        Pair
+}
+
+// Creates a new crawler for a market
+func NewUpBitCrawler(market types.Market) (UpBitCrawler, error) {
+	pair, err := UpBitMarkets.Resolve(UPBIT_MODULE_NAME, market)
+	if err != nil {
+		return UpBitCrawler{}, err
+	}
+
+	return UpBitCrawler{
+		DataCrawler: NewCrawler(fmt.Sprintf(UPBIT_APIURL, pair.Symbol)),
+		Market:      market,
+		Pair:        pair,
+	}, nil
+}
+
+// Return the name of this crawler
+func (c UpBitCrawler) GetName() string {
+	return UPBIT_MODULE_NAME
+}
+
+func (c UpBitCrawler) GetTicker() string {
+	return c.Pair.Symbol
+}
+
+func (c UpBitCrawler) GetMarket() types.Market {
+	return c.Market
+}
+
+// Serializes a json to a QuotePriceInfo type. The answer is a list with a ticker for each requested market
+func (c UpBitCrawler) ToQuotePriceInfo(jsonData []byte) (types.QuotePriceInfo, error) {
+
+	var result types.QuotePriceInfo
+	var aux []struct {
+		Market      string  `json:"market"`
+		LastPrice   float64 `json:"trade_price"`
+		OpenPrice   float64 `json:"opening_price"`
+		HighPrice   float64 `json:"high_price"`
+		Volume      float64 `json:"acc_trade_volume_24h"`
+		QuoteVolume float64 `json:"acc_trade_price_24h"`
+	}
+
+	if err := json.Unmarshal(jsonData, &aux); err != nil {
+		return result, err
+	}
+
+	for _, ticker := range aux {
+		if ticker.Market != c.Pair.Symbol {
+			continue
+		}
+
+		result = types.QuotePriceInfo{}
+		result.LastPrice = ticker.LastPrice
+		result.OpenPrice = ticker.OpenPrice
+		result.HighPrice = ticker.HighPrice
+		result.Volume = ticker.Volume
+		result.QuoteVolume = ticker.QuoteVolume
+		result.QuoteCurrency = c.Pair.Quote
+
+		return result, nil
+	}
+
+	return result, fmt.Errorf("there is no ticker for %s in the answer from UpBit", c.Pair.Symbol)
+}
+
+// Helper function to convert the json from UpBit´s API to a QuotePriceInfo instance
+func (c UpBitCrawler) Crawl(ctx context.Context) (types.QuotePriceInfo, error) {
+
+	jsonData, err := c.DataCrawler.Get(ctx)
+	if err != nil {
+		return types.QuotePriceInfo{}, err
+	}
+
+	priceInfo, err := c.ToQuotePriceInfo(jsonData)
+	if err != nil {
+		return priceInfo, err
+	}
+	priceInfo.Timestamp = time.Now().Unix()
+	priceInfo.DataURL = c.DataCrawler.Url
+
+	return priceInfo, nil
+}
@@ -0,0 +1,56 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package crawlers
+
+import (
+	"testing"
+)
+
+func TestUpBitToQuotePriceInfo(t *testing.T) {
+
+	ticker := readSample(t, "upbit_ticker.json")
+
+	tests := []struct {
+		name    string
+		market  string
+		ticker  []byte
+		wantErr bool
+	}{
+		{name: "sample", market: "BTC/KRW", ticker: ticker},
+		{name: "market missing from the answer", market: "ETH/KRW", ticker: ticker, wantErr: true},
+		{name: "empty answer", market: "BTC/KRW", ticker: []byte("[]"), wantErr: true},
+		{name: "invalid answer", market: "BTC/KRW", ticker: []byte(`{"market": "KRW-BTC"}`), wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			crawler, err := NewUpBitCrawler(parseMarket(t, test.market))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			info, err := crawler.ToQuotePriceInfo(test.ticker)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("expected an error, got %+v", info)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if info.LastPrice != 10233000 || info.OpenPrice != 10700000 || info.HighPrice != 10713000 {
+				t.Errorf("unexpected prices: %+v", info)
+			}
+			if info.Volume != 3935.20934419 || info.QuoteVolume != 41270398287.85372 {
+				t.Errorf("unexpected volumes: %+v", info)
+			}
+			// The prices are in won, to be converted or routed to a KRW index
+			if info.QuoteCurrency != "KRW" {
+				t.Errorf("QuoteCurrency = %q, want KRW", info.QuoteCurrency)
+			}
+		})
+	}
+}
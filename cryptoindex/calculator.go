@@ -1 +1,88 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+
+// Package cryptoindex contains the algorithms to aggregate the evidence crawled from the exchanges in a single price index
 package cryptoindex
+
+import (
+	"errors"
+	"sort"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// ErrNoEvidence is returned when none of the sources can be used to calculate the index
+var ErrNoEvidence = errors.New("there is no valid evidence to calculate the index")
+
+// Index is the aggregated value calculated from the evidence collected in a round
+type Index struct {
+	Price   float64            // Volume-weighted median price
+	Volume  float64            // Total volume of the sources used in the calculation
+	Weights map[string]float64 // Weight of each source in the index, by crawler name. The sum of all weights is 1
+}
+
+// A price and the volume supporting it
+type weightedPrice struct {
+	source string
+	price  float64
+	volume float64
+}
+
+// Calculate returns the volume-weighted median price for the results of a round.
+// Sources with errors, excluded, or without price or volume, are ignored
+func Calculate(sources []types.Result) (Index, error) {
+
+	var prices []weightedPrice
+	var totalVolume float64
+
+	for _, source := range sources {
+		if !isCandidate(source) || source.Data.Volume <= 0 {
+			continue
+		}
+		prices = append(prices, weightedPrice{
+			source: source.CrawlerName,
+			price:  source.Price(),
+			volume: source.Data.Volume,
+		})
+		totalVolume += source.Data.Volume
+	}
+
+	if len(prices) == 0 {
+		return Index{}, ErrNoEvidence
+	}
+
+	index := Index{
+		Price:   weightedMedian(prices, totalVolume),
+		Volume:  totalVolume,
+		Weights: make(map[string]float64),
+	}
+	for _, p := range prices {
+		index.Weights[p.source] += p.volume / totalVolume
+	}
+
+	return index, nil
+}
+
+// Returns the price where the cumulative volume reaches half of the total volume. When the half falls exactly
+// between two prices, the result is the mean of both
+func weightedMedian(prices []weightedPrice, totalVolume float64) float64 {
+
+	sort.Slice(prices, func(i, j int) bool {
+		return prices[i].price < prices[j].price
+	})
+
+	half := totalVolume / 2
+	var cumulative float64
+	for i, p := range prices {
+		cumulative += p.volume
+		if cumulative > half {
+			return p.price
+		}
+		if cumulative == half && i+1 < len(prices) {
+			return (p.price + prices[i+1].price) / 2
+		}
+	}
+
+	return prices[len(prices)-1].price
+}
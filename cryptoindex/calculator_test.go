@@ -0,0 +1,170 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package cryptoindex
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// Creates the evidence of a source with a price and its volume
+func source(name string, price float64, volume float64) types.Result {
+	return types.Result{
+		CrawlerName: name,
+		Data:        types.QuotePriceInfo{LastPrice: price, Volume: volume},
+	}
+}
+
+// Creates the evidence of a source that failed
+func failedSource(name string) types.Result {
+	return types.Result{CrawlerName: name, HasError: true, Error: "timeout"}
+}
+
+// Creates the evidence of a source excluded for a reason
+func excludedSource(name string, price float64, volume float64, reason string) types.Result {
+	result := source(name, price, volume)
+	result.Excluded = true
+	result.ExclusionReason = reason
+	return result
+}
+
+func TestCalculate(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		sources []types.Result
+		wantErr error
+		price   float64
+		volume  float64
+	}{
+		{
+			name:    "single source",
+			sources: []types.Result{source("a", 100, 2)},
+			price:   100,
+			volume:  2,
+		},
+		{
+			name:    "odd weights",
+			sources: []types.Result{source("a", 100, 1), source("b", 300, 1), source("c", 200, 3)},
+			price:   200,
+			volume:  5,
+		},
+		{
+			name:    "heaviest source at one end",
+			sources: []types.Result{source("a", 100, 1), source("b", 200, 1), source("c", 300, 5)},
+			price:   300,
+			volume:  7,
+		},
+		{
+			name:    "even weights, half between two prices",
+			sources: []types.Result{source("a", 200, 1), source("b", 100, 1)},
+			price:   150,
+			volume:  2,
+		},
+		{
+			name:    "even weights, half after two sources",
+			sources: []types.Result{source("a", 100, 1), source("b", 200, 2), source("c", 300, 1), source("d", 400, 4)},
+			price:   350,
+			volume:  8,
+		},
+		{
+			name:    "zero total volume",
+			sources: []types.Result{source("a", 100, 0), source("b", 200, 0)},
+			wantErr: ErrNoEvidence,
+		},
+		{
+			name:    "sources without volume are ignored",
+			sources: []types.Result{source("a", 100, 0), source("b", 200, 1), source("c", 220, 1)},
+			price:   210,
+			volume:  2,
+		},
+		{
+			name:    "all sources with errors",
+			sources: []types.Result{failedSource("a"), failedSource("b")},
+			wantErr: ErrNoEvidence,
+		},
+		{
+			name:    "no sources",
+			wantErr: ErrNoEvidence,
+		},
+		{
+			name:    "errors are ignored",
+			sources: []types.Result{failedSource("a"), source("b", 100, 1), source("c", 200, 3)},
+			price:   200,
+			volume:  4,
+		},
+		{
+			name:    "excluded sources are ignored",
+			sources: []types.Result{source("a", 100, 1), source("b", 200, 1), excludedSource("c", 10000, 10, types.ExclusionOutlierMAD)},
+			price:   150,
+			volume:  2,
+		},
+		{
+			name:    "all sources excluded",
+			sources: []types.Result{excludedSource("a", 100, 1, types.ExclusionQuoteMismatch), excludedSource("b", 200, 1, types.ExclusionOutlierBand)},
+			wantErr: ErrNoEvidence,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			index, err := Calculate(test.sources)
+			if test.wantErr != nil {
+				if err != test.wantErr {
+					t.Fatalf("error %v, want %v", err, test.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if index.Price != test.price {
+				t.Errorf("Price = %v, want %v", index.Price, test.price)
+			}
+			if index.Volume != test.volume {
+				t.Errorf("Volume = %v, want %v", index.Volume, test.volume)
+			}
+			var total float64
+			for _, weight := range index.Weights {
+				total += weight
+			}
+			if math.Abs(total-1) > 1e-9 {
+				t.Errorf("the weights %v add up to %v", index.Weights, total)
+			}
+		})
+	}
+}
+
+func TestCalculateWeights(t *testing.T) {
+
+	index, err := Calculate([]types.Result{source("a", 100, 1), source("b", 200, 3), failedSource("c")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if index.Weights["a"] != 0.25 || index.Weights["b"] != 0.75 {
+		t.Errorf("Weights = %v, want a: 0.25 and b: 0.75", index.Weights)
+	}
+	if _, ok := index.Weights["c"]; ok {
+		t.Errorf("the source with an error has a weight: %v", index.Weights)
+	}
+}
+
+// The prices quoted in other currency are used with their conversion rate
+func TestCalculateConverted(t *testing.T) {
+
+	converted := source("a", 1000, 3)
+	converted.ConversionRate = 0.1
+	index, err := Calculate([]types.Result{converted, source("b", 200, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if index.Price != 100 {
+		t.Errorf("Price = %v, want the converted price 100", index.Price)
+	}
+}
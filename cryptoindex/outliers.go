@@ -0,0 +1,115 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package cryptoindex
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// OutlierMethod is the rule used to decide if a price is too far from the consensus of the round
+type OutlierMethod int
+
+const (
+	// MedianAbsoluteDeviation rejects the prices further than Threshold times the (scaled) MAD from the median
+	MedianAbsoluteDeviation OutlierMethod = iota
+	// PercentageBand rejects the prices further than Threshold (0.05 = 5%) from the median
+	PercentageBand
+)
+
+// Scale factor to use the MAD as a consistent estimator of the standard deviation
+const madScale = 1.4826
+
+// OutlierPolicy configures the rejection of outliers before the aggregation
+type OutlierPolicy struct {
+	Method    OutlierMethod
+	Threshold float64
+	// MinBand is the relative distance to the median (0.005 = 0.5%) always accepted, whatever the method says.
+	// It avoids rejections when most of the sources agree and the MAD is almost zero
+	MinBand float64
+}
+
+// DefaultOutlierPolicy rejects the prices further than 3 scaled MADs from the median, with a tolerance of 0.5%
+var DefaultOutlierPolicy = OutlierPolicy{
+	Method:    MedianAbsoluteDeviation,
+	Threshold: 3,
+	MinBand:   0.005,
+}
+
+// RejectOutliers marks as excluded the sources with a price too far from the median of the round. The excluded
+// sources are not removed, so they can be stored as evidence. It fails if the hash of an excluded source can´t be
+// updated
+func RejectOutliers(sources []types.Result, policy OutlierPolicy) ([]types.Result, error) {
+
+	var prices []float64
+	for _, source := range sources {
+		if isCandidate(source) {
+			prices = append(prices, source.Price())
+		}
+	}
+	if len(prices) < 3 { // With less than three prices there is no way to know which one is wrong
+		return sources, nil
+	}
+
+	consensus := median(prices)
+	var deviations []float64
+	for _, price := range prices {
+		deviations = append(deviations, math.Abs(price-consensus))
+	}
+	mad := median(deviations) * madScale
+
+	for i := range sources {
+		if !isCandidate(sources[i]) {
+			continue
+		}
+
+		deviation := math.Abs(sources[i].Price() - consensus)
+		if deviation <= consensus*policy.MinBand {
+			continue
+		}
+
+		var reason string
+		switch policy.Method {
+		case MedianAbsoluteDeviation:
+			if deviation > policy.Threshold*mad {
+				reason = types.ExclusionOutlierMAD
+			}
+		case PercentageBand:
+			if deviation > consensus*policy.Threshold {
+				reason = types.ExclusionOutlierBand
+			}
+		}
+
+		if reason != "" {
+			log.Printf("Excluding %s: price %f too far from the median %f (%s)", sources[i].CrawlerName, sources[i].Price(), consensus, reason)
+			if err := sources[i].Exclude(reason); err != nil {
+				return sources, fmt.Errorf("unable to exclude %s: %v", sources[i].CrawlerName, err)
+			}
+		}
+	}
+
+	return sources, nil
+}
+
+// A source with a price that could be part of the index
+func isCandidate(source types.Result) bool {
+	return !source.HasError && !source.Excluded && source.Price() > 0
+}
+
+// Returns the median of the values, without modify the slice
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+
+	return sorted[middle]
+}
@@ -0,0 +1,115 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package cryptoindex
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+func TestRejectOutliers(t *testing.T) {
+
+	noBand := DefaultOutlierPolicy
+	noBand.MinBand = 0
+	band := OutlierPolicy{Method: PercentageBand, Threshold: 0.05, MinBand: 0.005}
+
+	tests := []struct {
+		name     string
+		sources  []types.Result
+		policy   OutlierPolicy
+		excluded map[string]string // Reasons of the excluded sources, by name
+	}{
+		{
+			name:    "two prices",
+			sources: []types.Result{source("a", 100, 1), source("b", 200, 1)},
+			policy:  DefaultOutlierPolicy,
+		},
+		{
+			// The failed and excluded sources don´t count as samples
+			name:    "two healthy prices",
+			sources: []types.Result{source("a", 100, 1), source("b", 200, 1), failedSource("c"), excludedSource("d", 100, 1, types.ExclusionQuoteMismatch)},
+			policy:  DefaultOutlierPolicy,
+		},
+		{
+			// Median 101 and scaled MAD 1.4826: the limit is 4.4478 from the median
+			name:     "beyond the MAD threshold",
+			sources:  []types.Result{source("a", 100, 1), source("b", 101, 1), source("c", 200, 1)},
+			policy:   DefaultOutlierPolicy,
+			excluded: map[string]string{"c": types.ExclusionOutlierMAD},
+		},
+		{
+			name:    "within the MAD threshold",
+			sources: []types.Result{source("a", 100, 1), source("b", 101, 1), source("c", 105, 1)},
+			policy:  DefaultOutlierPolicy,
+		},
+		{
+			name:     "zero MAD",
+			sources:  []types.Result{source("a", 100, 1), source("b", 100, 1), source("c", 100, 1), source("d", 105, 1)},
+			policy:   DefaultOutlierPolicy,
+			excluded: map[string]string{"d": types.ExclusionOutlierMAD},
+		},
+		{
+			// The minimum band accepts 0.5 from the median, even if the MAD is zero
+			name:    "zero MAD within the minimum band",
+			sources: []types.Result{source("a", 100, 1), source("b", 100, 1), source("c", 100, 1), source("d", 100.4, 1)},
+			policy:  DefaultOutlierPolicy,
+		},
+		{
+			name:     "zero MAD without minimum band",
+			sources:  []types.Result{source("a", 100, 1), source("b", 100, 1), source("c", 100, 1), source("d", 100.01, 1)},
+			policy:   noBand,
+			excluded: map[string]string{"d": types.ExclusionOutlierMAD},
+		},
+		{
+			name:     "beyond the percentage band",
+			sources:  []types.Result{source("a", 100, 1), source("b", 101, 1), source("c", 110, 1)},
+			policy:   band,
+			excluded: map[string]string{"c": types.ExclusionOutlierBand},
+		},
+		{
+			name:    "within the percentage band",
+			sources: []types.Result{source("a", 100, 1), source("b", 101, 1), source("c", 105, 1)},
+			policy:  band,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			sources, err := RejectOutliers(test.sources, test.policy)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(sources) != len(test.sources) {
+				t.Fatalf("%d sources, want all the %d", len(sources), len(test.sources))
+			}
+
+			for _, source := range sources {
+				reason, excluded := test.excluded[source.CrawlerName]
+				if source.HasError || source.ExclusionReason == types.ExclusionQuoteMismatch {
+					continue
+				}
+				if source.Excluded != excluded || source.ExclusionReason != reason {
+					t.Errorf("source %s: excluded %v (%q), want %v (%q)", source.CrawlerName, source.Excluded, source.ExclusionReason, excluded, reason)
+				}
+				// The hash of the excluded sources covers the reason
+				if excluded {
+					if err := source.VerifyHash(); err != nil {
+						t.Errorf("source %s: %v", source.CrawlerName, err)
+					}
+				}
+			}
+		})
+	}
+}
+
+// A source that can´t be hashed can´t be excluded
+func TestRejectOutliersHashError(t *testing.T) {
+
+	sources := []types.Result{source("a", 100, 1), source("b", 101, 1), source("c", 200, math.Inf(1))}
+	if _, err := RejectOutliers(sources, DefaultOutlierPolicy); err == nil {
+		t.Fatal("expected an error excluding a source with an infinite volume")
+	}
+}
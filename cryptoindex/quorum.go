@@ -0,0 +1,95 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package cryptoindex
+
+import (
+	"fmt"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// MinDistinctVenues is the minimum number of venues to calculate an index, whatever the policy says.
+// A price from a single venue is never published
+const MinDistinctVenues = 2
+
+// Reasons to reject a round when the quorum is not met
+const (
+	QuorumNotEnoughSources = "not-enough-sources"
+	QuorumNotEnoughVolume  = "not-enough-volume"
+	QuorumNotEnoughVenues  = "not-enough-venues"
+)
+
+// QuorumPolicy defines the minimum evidence needed to mint a block
+type QuorumPolicy struct {
+	MinSources     int     // Minimum number of healthy sources
+	MinVolumeShare float64 // Minimum share (0.5 = 50%) of the volume reported in the round held by the healthy sources
+	MinVenues      int     // Minimum number of distinct venues. Never less than MinDistinctVenues
+}
+
+// DefaultQuorumPolicy needs two healthy sources, from two venues, with the half of the volume of the round
+var DefaultQuorumPolicy = QuorumPolicy{
+	MinSources:     2,
+	MinVolumeShare: 0.5,
+	MinVenues:      MinDistinctVenues,
+}
+
+// QuorumError is returned when the evidence of a round doesn´t meet the quorum
+type QuorumError struct {
+	Reason      string // One of the QuorumNotEnough* values
+	Sources     int
+	Venues      int
+	VolumeShare float64
+}
+
+func (e *QuorumError) Error() string {
+	return fmt.Sprintf("quorum not met (%s): %d healthy sources, %d venues, %.2f%% of the volume",
+		e.Reason, e.Sources, e.Venues, e.VolumeShare*100)
+}
+
+// CheckQuorum verifies that the healthy sources of a round are enough to calculate an index.
+// It returns a *QuorumError if they are not
+func CheckQuorum(sources []types.Result, policy QuorumPolicy) error {
+
+	var healthy int
+	var totalVolume, healthyVolume float64
+	venues := make(map[string]bool)
+
+	for _, source := range sources {
+		if source.Data.Volume > 0 {
+			totalVolume += source.Data.Volume
+		}
+		if !isCandidate(source) || source.Data.Volume <= 0 {
+			continue
+		}
+		healthy++
+		healthyVolume += source.Data.Volume
+		venues[source.CrawlerName] = true
+	}
+
+	quorumErr := &QuorumError{
+		Sources: healthy,
+		Venues:  len(venues),
+	}
+	if totalVolume > 0 {
+		quorumErr.VolumeShare = healthyVolume / totalVolume
+	}
+
+	minVenues := policy.MinVenues
+	if minVenues < MinDistinctVenues {
+		minVenues = MinDistinctVenues
+	}
+
+	switch {
+	case healthy == 0 || healthy < policy.MinSources:
+		quorumErr.Reason = QuorumNotEnoughSources
+	case len(venues) < minVenues:
+		quorumErr.Reason = QuorumNotEnoughVenues
+	case quorumErr.VolumeShare < policy.MinVolumeShare:
+		quorumErr.Reason = QuorumNotEnoughVolume
+	default:
+		return nil
+	}
+
+	return quorumErr
+}
@@ -0,0 +1,129 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package cryptoindex
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+func TestCheckQuorum(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		sources []types.Result
+		policy  QuorumPolicy
+		reason  string // Empty when the quorum is met
+		healthy int
+		venues  int
+		share   float64
+	}{
+		{
+			name:    "quorum met",
+			sources: []types.Result{source("a", 100, 1), source("b", 101, 1), failedSource("c")},
+			policy:  DefaultQuorumPolicy,
+		},
+		{
+			name:    "no sources",
+			policy:  DefaultQuorumPolicy,
+			reason:  QuorumNotEnoughSources,
+			healthy: 0,
+		},
+		{
+			name:    "all sources failed",
+			sources: []types.Result{failedSource("a"), failedSource("b")},
+			policy:  QuorumPolicy{},
+			reason:  QuorumNotEnoughSources,
+			healthy: 0,
+		},
+		{
+			name:    "less sources than the minimum",
+			sources: []types.Result{source("a", 100, 1), source("b", 101, 1), failedSource("c")},
+			policy:  QuorumPolicy{MinSources: 3, MinVolumeShare: 0.5, MinVenues: 2},
+			reason:  QuorumNotEnoughSources,
+			healthy: 2,
+			venues:  2,
+			share:   1,
+		},
+		{
+			name:    "sources without volume are not healthy",
+			sources: []types.Result{source("a", 100, 1), source("b", 101, 0)},
+			policy:  DefaultQuorumPolicy,
+			reason:  QuorumNotEnoughSources,
+			healthy: 1,
+			venues:  1,
+			share:   1,
+		},
+		{
+			name:    "a single venue",
+			sources: []types.Result{source("a", 100, 1), source("a", 101, 1)},
+			policy:  DefaultQuorumPolicy,
+			reason:  QuorumNotEnoughVenues,
+			healthy: 2,
+			venues:  1,
+			share:   1,
+		},
+		{
+			// A policy can´t ask for less than MinDistinctVenues
+			name:    "a single venue with a lower minimum",
+			sources: []types.Result{source("a", 100, 1), source("a", 101, 1)},
+			policy:  QuorumPolicy{MinSources: 1, MinVenues: 1},
+			reason:  QuorumNotEnoughVenues,
+			healthy: 2,
+			venues:  1,
+			share:   1,
+		},
+		{
+			name:    "less venues than the minimum",
+			sources: []types.Result{source("a", 100, 1), source("b", 101, 1)},
+			policy:  QuorumPolicy{MinSources: 2, MinVenues: 3},
+			reason:  QuorumNotEnoughVenues,
+			healthy: 2,
+			venues:  2,
+			share:   1,
+		},
+		{
+			name:    "most of the volume excluded",
+			sources: []types.Result{source("a", 100, 1), source("b", 101, 1), excludedSource("c", 150, 6, types.ExclusionOutlierMAD)},
+			policy:  DefaultQuorumPolicy,
+			reason:  QuorumNotEnoughVolume,
+			healthy: 2,
+			venues:  2,
+			share:   0.25,
+		},
+		{
+			name:    "half of the volume excluded",
+			sources: []types.Result{source("a", 100, 1), source("b", 101, 1), excludedSource("c", 150, 2, types.ExclusionOutlierMAD)},
+			policy:  DefaultQuorumPolicy,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			err := CheckQuorum(test.sources, test.policy)
+			if test.reason == "" {
+				if err != nil {
+					t.Fatalf("quorum not met: %v", err)
+				}
+				return
+			}
+
+			quorumErr, ok := err.(*QuorumError)
+			if !ok {
+				t.Fatalf("error %v, want a *QuorumError", err)
+			}
+			if quorumErr.Reason != test.reason {
+				t.Errorf("Reason = %q, want %q", quorumErr.Reason, test.reason)
+			}
+			if quorumErr.Sources != test.healthy || quorumErr.Venues != test.venues || quorumErr.VolumeShare != test.share {
+				t.Errorf("%d sources, %d venues and %v of the volume, want %d, %d and %v", quorumErr.Sources, quorumErr.Venues, quorumErr.VolumeShare, test.healthy, test.venues, test.share)
+			}
+			if !strings.Contains(quorumErr.Error(), test.reason) {
+				t.Errorf("the error %q doesn´t tell the reason", quorumErr.Error())
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package cryptoindex
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// QuoteRates are fixed rates to convert the prices from a quote currency to another one, indexed by "FROM/TO"
+type QuoteRates map[string]float64
+
+// DefaultQuoteRates only accepts USDT as USD. Any other currency must be routed to its own index
+var DefaultQuoteRates = QuoteRates{
+	"USDT/USD": 1,
+}
+
+// Rate returns the rate to convert a price from a currency to another one
+func (r QuoteRates) Rate(from string, to string) (float64, bool) {
+	rate, ok := r[from+"/"+to]
+	return rate, ok
+}
+
+// RouteQuotes keeps only the sources quoted in the currency of the index. The sources quoted in other currencies
+// are converted if there is a rate for them, or excluded. It fails if the hash of a source can´t be updated
+func RouteQuotes(sources []types.Result, quotedCurrency string, rates QuoteRates) ([]types.Result, error) {
+
+	for i := range sources {
+		currency := sources[i].Data.QuoteCurrency
+		if sources[i].HasError || sources[i].Excluded || currency == "" || currency == quotedCurrency {
+			continue
+		}
+
+		if rate, ok := rates.Rate(currency, quotedCurrency); ok {
+			if err := sources[i].Convert(rate); err != nil {
+				return sources, fmt.Errorf("unable to convert %s: %v", sources[i].CrawlerName, err)
+			}
+			continue
+		}
+
+		log.Printf("Excluding %s: quoted in %s instead of %s", sources[i].CrawlerName, currency, quotedCurrency)
+		if err := sources[i].Exclude(types.ExclusionQuoteMismatch); err != nil {
+			return sources, fmt.Errorf("unable to exclude %s: %v", sources[i].CrawlerName, err)
+		}
+	}
+
+	return sources, nil
+}
@@ -0,0 +1,111 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package cryptoindex
+
+import (
+	"math"
+	"testing"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// Creates the evidence of a source with the price quoted in a currency
+func quotedSource(name string, price float64, currency string) types.Result {
+	result := source(name, price, 1)
+	result.Data.QuoteCurrency = currency
+	return result
+}
+
+func TestRouteQuotes(t *testing.T) {
+
+	rates := QuoteRates{
+		"USDT/USD": 1,
+		"KRW/USD":  0.00085,
+	}
+	failed := failedSource("failed")
+	failed.Data.QuoteCurrency = "EUR"
+
+	sources := []types.Result{
+		quotedSource("usd", 9000, "USD"),
+		quotedSource("unknown", 9000, ""),
+		quotedSource("usdt", 9010, "USDT"),
+		quotedSource("krw", 10600000, "KRW"),
+		quotedSource("eur", 8200, "EUR"),
+		failed,
+	}
+	routed, err := RouteQuotes(sources, "USD", rates)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name     string
+		rate     float64
+		price    float64
+		excluded bool
+	}{
+		{name: "usd", price: 9000},
+		// Without currency, the source is taken as quoted in the currency of the index
+		{name: "unknown", price: 9000},
+		{name: "usdt", rate: 1, price: 9010},
+		{name: "krw", rate: 0.00085, price: 9010},
+		{name: "eur", price: 8200, excluded: true},
+		{name: "failed"},
+	}
+	for i, test := range tests {
+		source := routed[i]
+		if source.CrawlerName != test.name {
+			t.Fatalf("source %d is %s, want %s", i, source.CrawlerName, test.name)
+		}
+		if source.ConversionRate != test.rate {
+			t.Errorf("source %s: rate %v, want %v", test.name, source.ConversionRate, test.rate)
+		}
+		if math.Abs(source.Price()-test.price) > 1e-6 {
+			t.Errorf("source %s: price %v, want %v", test.name, source.Price(), test.price)
+		}
+		if source.Excluded != test.excluded {
+			t.Errorf("source %s: excluded %v, want %v", test.name, source.Excluded, test.excluded)
+		}
+		if test.excluded && source.ExclusionReason != types.ExclusionQuoteMismatch {
+			t.Errorf("source %s: reason %q, want %q", test.name, source.ExclusionReason, types.ExclusionQuoteMismatch)
+		}
+		// The hash covers the rate and the reason of the exclusion
+		if test.rate != 0 || test.excluded {
+			if err := source.VerifyHash(); err != nil {
+				t.Errorf("source %s: %v", test.name, err)
+			}
+		}
+	}
+}
+
+// With the default rates, the prices in won are never mixed with the prices in dollars
+func TestRouteQuotesDefaultRates(t *testing.T) {
+
+	routed, err := RouteQuotes([]types.Result{quotedSource("krw", 10600000, "KRW")}, "USD", DefaultQuoteRates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !routed[0].Excluded {
+		t.Errorf("the source quoted in KRW was not excluded: %+v", routed[0])
+	}
+
+	// The same source in its own index
+	routed, err = RouteQuotes([]types.Result{quotedSource("krw", 10600000, "KRW")}, "KRW", DefaultQuoteRates)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if routed[0].Excluded || routed[0].ConversionRate != 0 {
+		t.Errorf("the source quoted in KRW was changed in the KRW index: %+v", routed[0])
+	}
+}
+
+// A source that can´t be hashed can´t be converted
+func TestRouteQuotesHashError(t *testing.T) {
+
+	source := quotedSource("usdt", 9010, "USDT")
+	source.Data.Volume = math.Inf(1)
+	if _, err := RouteQuotes([]types.Result{source}, "USD", DefaultQuoteRates); err == nil {
+		t.Fatal("expected an error converting a source with an infinite volume")
+	}
+}
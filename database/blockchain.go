@@ -3,8 +3,12 @@ package database
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"log"
-	"time"
+	"math"
+	"strconv"
+	"sync"
 
 	"github.com/aquarelle-tech/darkmatter/types"
 )
@@ -23,89 +27,330 @@ const (
 
 	// LatestBlockKey is the literal to be used as a key to index the latest block in the database
 	LatestBlockKey = "latest"
+
+	// Number of blocks read from the store at once when the whole chain is walked
+	walkPageSize = 1000
 )
 
+// ErrReadOnly is returned when a block is written to a chain opened with WithReadOnly
+var ErrReadOnly = errors.New("the chain is opened as read-only")
+
 // BlockChain is the main data model to handle the blocks
 type BlockChain struct {
-	Name string
+	Name      string
 	IsTestnet bool
 
+	mutex       sync.Mutex // Serializes the creation of blocks
 	latestBlock *types.FullSignedBlock
-	kvstore types.KVStore
+	kvstore     types.KVStore
+	signer      types.BlockSigner // Signs the new blocks. Without signer, the blocks have no address nor signature
+	readOnly    bool
+}
+
+// Option configures the storage of a blockchain created with NewBlockChain
+type Option func(*chainOptions)
+
+type chainOptions struct {
+	newStore func(locationDirectory string, readOnly bool) (types.KVStore, error)
+	signer   types.BlockSigner
+	readOnly bool
 }
 
-// NewBlockChain initializes and creates a new manager of a blockchain
-func NewBlockChain (name string, locationDirectory string) *BlockChain {
-	return &BlockChain {
-		Name: name,
-		kvstore: NewKVStore (locationDirectory),
+// WithSigner signs the new blocks with the identity of the node, whose address is set in the blocks
+func WithSigner(signer types.BlockSigner) Option {
+	return func(o *chainOptions) {
+		o.signer = signer
 	}
 }
-// NewFullSignedBlock creates a new signed block to store
-func (db *BlockChain) NewFullSignedBlock(ticker string, avgPrice float64, avgVolumen float64, sources []types.Result, memo string) types.FullSignedBlock {
 
-	// Create a "protomessage" in order to be hashed with the hash inside
-	var latestHash string
-	var height uint64
+// WithReadOnly opens the chain without writing to the storage: the stores are not created nor migrated, and the
+// latest pointer is not reconciled with the stored blocks, so Verify can report it. The new blocks are refused with
+// ErrReadOnly
+func WithReadOnly() Option {
+	return func(o *chainOptions) {
+		o.readOnly = true
+	}
+}
 
-	if db.latestBlock == nil { // try to get the stored block
-		db.ReadLatestBlock()
+// WithStore uses an already opened store for the blockchain, instead of a Badger store in the directory
+func WithStore(store types.KVStore) Option {
+	return func(o *chainOptions) {
+		o.newStore = func(string, bool) (types.KVStore, error) { return store, nil }
 	}
+}
 
-	if db.latestBlock != nil {
-		latestHash = db.latestBlock.Hash   // Yes, there is a latest block, so there is a "latest" of everything
-		height = db.latestBlock.Height + 1 // And a new heigth
+// WithMemoryStore keeps the blockchain in memory, without touching the disk. The blocks are lost on Close
+func WithMemoryStore() Option {
+	return func(o *chainOptions) {
+		o.newStore = func(string, bool) (types.KVStore, error) { return NewMemoryStore(), nil }
+	}
+}
+
+// WithSQLiteStore stores the blockchain in a SQLite database in the directory, to query the blocks and their
+// evidence with SQL
+func WithSQLiteStore() Option {
+	return func(o *chainOptions) {
+		o.newStore = func(directory string, readOnly bool) (types.KVStore, error) {
+			if readOnly {
+				return OpenSQLiteStoreReadOnly(directory)
+			}
+			return NewSQLiteStore(directory)
+		}
+	}
+}
+
+// NewBlockChain initializes and creates a new manager of a blockchain. By default the blocks are stored in a Badger
+// database in the directory. The storage is kept open until Close is called
+func NewBlockChain(name string, locationDirectory string, options ...Option) (*BlockChain, error) {
+	config := chainOptions{
+		newStore: func(directory string, readOnly bool) (types.KVStore, error) {
+			if readOnly {
+				return OpenKVStoreReadOnly(directory)
+			}
+			return NewKVStore(directory)
+		},
+	}
+	for _, option := range options {
+		option(&config)
+	}
+
+	kvstore, err := config.newStore(locationDirectory, config.readOnly)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := &BlockChain{
+		Name:     name,
+		kvstore:  kvstore,
+		signer:   config.signer,
+		readOnly: config.readOnly,
+	}
+	if chain.readOnly {
+		chain.ReadLatestBlock()
+		return chain, nil
+	}
+	if err := chain.reconcileLatestBlock(); err != nil {
+		kvstore.Close()
+		return nil, err
+	}
+
+	return chain, nil
+}
+
+// Load the latest block, making sure that it is the highest block stored. The chains written before the atomic
+// commit of the blocks can have a latest pointer behind (or ahead of) the stored blocks
+func (db *BlockChain) reconcileLatestBlock() error {
+
+	highest, err := db.kvstore.BlocksByHeight(0, math.MaxUint64, 1, true)
+	if err != nil {
+		return err
+	}
+	db.ReadLatestBlock()
+
+	if len(highest) == 0 {
+		if db.latestBlock != nil {
+			log.Printf("The latest block of %s (%s) is not stored. The chain starts again from the genesis block", db.Name, db.latestBlock.Hash)
+			db.latestBlock = nil
+		}
+		return nil
+	}
+	if db.latestBlock != nil && db.latestBlock.Hash == highest[0].Hash {
+		return nil
 	}
 
-	block := types.FullSignedBlock{
-		Height:        height,
-		AveragePrice:  avgVolumen,
-		AverageVolume: avgPrice,
+	log.Printf("The latest block of %s is moved to the highest stored block %d (%s)", db.Name, highest[0].Height, highest[0].Hash)
+	db.latestBlock = &highest[0]
+	bytes, err := json.Marshal(db.latestBlock)
+	if err != nil {
+		return err
+	}
+
+	return db.kvstore.StoreValue(LatestBlockKey, bytes)
+}
+
+// Close releases the storage of the blockchain
+func (db *BlockChain) Close() error {
+	return db.kvstore.Close()
+}
+
+// NewFullSignedBlock creates a new signed block and stores it. The block, its indexes and the latest pointer are
+// written in a single transaction: if it fails, the chain doesn´t change
+func (db *BlockChain) NewFullSignedBlock(ticker string, timestamp uint64, avgPrice float64, avgVolumen float64, sources []types.Result, memo string) (types.FullSignedBlock, error) {
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	return db.appendBlock(types.FullSignedBlock{
+		AveragePrice:  avgPrice,
+		AverageVolume: avgVolumen,
 		Ticker:        ticker,
-		Timestamp:     uint64(time.Now().Unix()),
-		PreviousHash:  latestHash, // Chain the current hash with the previous one
+		Timestamp:     timestamp,
+		MerkleRoot:    types.EvidenceRoot(sources),
 		Evidence:      sources,
 		Memo:          memo,
+	})
+}
+
+// Chain a block to the latest one, sign it and store it. The caller must hold the mutex
+func (db *BlockChain) appendBlock(block types.FullSignedBlock) (types.FullSignedBlock, error) {
+
+	if db.readOnly {
+		return block, ErrReadOnly
 	}
-	// Other settings
-	block.CreateHash()
+
+	// Create a "protomessage" in order to be hashed with the hash inside
 	if db.latestBlock != nil {
-		block.PreviousAddress = db.latestBlock.Address // Link with previous block
+		block.PreviousHash = db.latestBlock.Hash       // Chain the current hash with the previous one
+		block.Height = db.latestBlock.Height + 1       // And a new heigth
+		block.PreviousAddress = db.latestBlock.Address // Link with previous block. It is part of the hash, so it can be verified
+
+		// Once the chain is signed, only the key announced by the latest block can sign the next one. An announcement
+		// not covered by the hash of the latest block could have been added by anyone
+		if db.latestBlock.NextAddress != "" && db.latestBlock.HashVersion < types.KeyRotationHashVersion {
+			return block, fmt.Errorf("%w: the latest block of %s announces %s without hashing it", ErrUnexpectedSigner, db.Name, db.latestBlock.NextAddress)
+		}
+		if expected := db.latestBlock.NextSigner(); expected != "" && (db.signer == nil || db.signer.Address() != expected) {
+			return block, fmt.Errorf("%w: the next block of %s must be signed by %s", ErrUnexpectedSigner, db.Name, expected)
+		}
 	}
 
-	db.kvstore.StoreBlock(block)
-	// Latest block
-	db.latestBlock = &block
-	bytes, err := json.Marshal(block)
-	if err != nil {
-		panic (err) //TODO: This error is important!! means that there was not able to create a new block! Needs more code to manage this event
+	// Other settings
+	if db.signer != nil {
+		if err := block.Sign(db.signer); err != nil {
+			return block, err
+		}
+	} else if err := block.CreateHash(); err != nil {
+		return block, err
 	}
-	db.kvstore.StoreValue(LatestBlockKey, bytes)
+
+	// Store the block as the latest one
+	if err := db.kvstore.CommitBlock(block, LatestBlockKey); err != nil {
+		return block, err
+	}
+	db.latestBlock = &block
 
 	log.Println("Created a new block", block)
-	return block
+	return block, nil
 }
 
-
+// GetBlockByHash returns a block from their hash
 func (db *BlockChain) GetBlockByHash(hash string) (*types.FullSignedBlock, error) {
-	return nil, nil
+	return db.kvstore.GetBlock(hash)
 }
 
-
-// Return a block from a weight value
+// Return a block from a weight value (the height of the block)
 func (db *BlockChain) GetBlockByWeight(weight int64) (*types.FullSignedBlock, error) {
-	return nil, nil
+	if weight < 0 {
+		return nil, fmt.Errorf("invalid block height %d", weight)
+	}
+	return db.kvstore.FindBlockByHeight(uint64(weight))
+}
+
+// GetBlockByHeight returns the block of a height
+func (db *BlockChain) GetBlockByHeight(height uint64) (*types.FullSignedBlock, error) {
+	return db.kvstore.FindBlockByHeight(height)
 }
 
 // Return a block from a timestamp value
 func (db *BlockChain) GetBlockByTimestamp(timestamp int64) (*types.FullSignedBlock, error) {
-	return nil, nil
+	if timestamp < 0 {
+		return nil, fmt.Errorf("invalid block timestamp %d", timestamp)
+	}
+	return db.kvstore.FindBlockByTimestamp(uint64(timestamp))
 }
 
-// Return a block from a timestamp value
-func (db *BlockChain) GetMany(startingTimestamp int64, previousCount int) ([]types.FullSignedBlock, error) {
+// BlocksBetweenHeights returns the blocks from the height from to the height to (both included), in order
+func (db *BlockChain) BlocksBetweenHeights(from uint64, to uint64) ([]types.FullSignedBlock, error) {
+	return db.kvstore.BlocksByHeight(from, to, 0, false)
+}
 
-	return nil, nil
+// BlocksBetween returns the blocks with a timestamp between from and to (both included), in order
+func (db *BlockChain) BlocksBetween(from int64, to int64) ([]types.FullSignedBlock, error) {
+	if from < 0 {
+		from = 0
+	}
+	if to < from {
+		return nil, nil
+	}
+	return db.kvstore.BlocksByTimestamp(uint64(from), uint64(to), 0, false)
+}
+
+// LastBlocksBefore returns the latest count blocks with a timestamp before or equal to timestamp, from the newest
+// to the oldest
+func (db *BlockChain) LastBlocksBefore(timestamp int64, count int) ([]types.FullSignedBlock, error) {
+	if timestamp < 0 || count <= 0 {
+		return nil, nil
+	}
+	return db.kvstore.BlocksByTimestamp(0, uint64(timestamp), count, true)
+}
+
+// Walk calls fn for every block of the chain, from the genesis block to the latest one. It stops at the first
+// error returned by fn
+func (db *BlockChain) Walk(fn func(block types.FullSignedBlock) error) error {
+
+	for from := uint64(0); ; {
+		blocks, err := db.kvstore.BlocksByHeight(from, math.MaxUint64, walkPageSize, false)
+		if err != nil {
+			return err
+		}
+
+		for _, block := range blocks {
+			if err := fn(block); err != nil {
+				return err
+			}
+		}
+
+		if len(blocks) < walkPageSize {
+			return nil
+		}
+		from = blocks[len(blocks)-1].Height + 1
+	}
+}
+
+// Page is a set of blocks returned by GetMany, from the newest to the oldest. Next is the cursor to read the
+// following page, and it is empty when there are no more blocks
+type Page struct {
+	Blocks []types.FullSignedBlock `json:"blocks"`
+	Next   string                  `json:"next,omitempty"`
+}
+
+// GetMany returns previousCount blocks before or at startingTimestamp, from the newest to the oldest. To read the
+// next page, call it again with the cursor of the previous page (startingTimestamp is ignored then)
+func (db *BlockChain) GetMany(startingTimestamp int64, previousCount int, cursor string) (Page, error) {
+
+	var page Page
+	var blocks []types.FullSignedBlock
+	var err error
+
+	if previousCount <= 0 {
+		return page, fmt.Errorf("invalid page size %d", previousCount)
+	}
+
+	// One more block is read to know if there is a next page
+	if cursor == "" {
+		if startingTimestamp < 0 {
+			return page, nil
+		}
+		blocks, err = db.kvstore.BlocksByTimestamp(0, uint64(startingTimestamp), previousCount+1, true)
+	} else {
+		var height uint64
+		if height, err = strconv.ParseUint(cursor, 10, 64); err != nil {
+			return page, fmt.Errorf("invalid cursor %q", cursor)
+		}
+		blocks, err = db.kvstore.BlocksByHeight(0, height, previousCount+1, true)
+	}
+	if err != nil {
+		return page, err
+	}
+
+	// The heights grow with the time, so the cursor is the height of the first block of the next page
+	if len(blocks) > previousCount {
+		page.Next = strconv.FormatUint(blocks[previousCount].Height, 10)
+		blocks = blocks[:previousCount]
+	}
+	page.Blocks = blocks
+
+	return page, nil
 }
 
 // Store the latest hash of the message
@@ -116,7 +361,7 @@ func (db *BlockChain) StoreLatestBlock() {
 		log.Println("Can´t store the latest block. Please check the KVStore urgently!!")
 		return // Don´t continue
 	}
-	db.kvstore.StoreValue (LatestBlockKey, bytes)
+	db.kvstore.StoreValue(LatestBlockKey, bytes)
 }
 
 // Get the latest stored block
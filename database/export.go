@@ -0,0 +1,171 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"io"
+	"strconv"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// Columns of the CSV exports
+var (
+	BlockCSVHeader = []string{
+		"hash", "height", "timestamp", "ticker", "avgPrice", "avgVolumen", "previousHash", "merkleRoot", "address",
+		"previousAddress", "memo", "sources",
+	}
+	EvidenceCSVHeader = []string{
+		"blockHash", "blockHeight", "blockTimestamp", "position", "name", "ticker", "timestamp", "hash",
+		"lastPrice", "volume", "quoteVolume", "highPrice", "openPrice", "bidPrice", "askPrice", "quoteCurrency",
+		"conversionRate", "hasError", "error", "excluded", "exclusionReason", "dataURL",
+	}
+)
+
+// Format a number for the CSV exports, with the precision needed to read it back
+func formatFloat(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+// ExportNDJSON writes all the blocks of the chain in height order, one json per line, with their full evidence.
+// It returns the number of blocks written
+func (db *BlockChain) ExportNDJSON(w io.Writer) (int, error) {
+
+	var count int
+	buffer := bufio.NewWriter(w)
+	encoder := json.NewEncoder(buffer)
+
+	err := db.Walk(func(block types.FullSignedBlock) error {
+		count++
+		return encoder.Encode(block) // Encode ends each block with a new line
+	})
+	if err != nil {
+		return count, err
+	}
+
+	return count, buffer.Flush()
+}
+
+// ExportBlocksCSV writes all the blocks of the chain in height order, one row for each block without their
+// evidence. It returns the number of blocks written
+func (db *BlockChain) ExportBlocksCSV(w io.Writer) (int, error) {
+
+	var count int
+	writer := csv.NewWriter(w)
+	if err := writer.Write(BlockCSVHeader); err != nil {
+		return count, err
+	}
+
+	err := db.Walk(func(block types.FullSignedBlock) error {
+		count++
+		return writer.Write([]string{
+			block.Hash,
+			strconv.FormatUint(block.Height, 10),
+			strconv.FormatUint(block.Timestamp, 10),
+			block.Ticker,
+			formatFloat(block.AveragePrice),
+			formatFloat(block.AverageVolume),
+			block.PreviousHash,
+			block.MerkleRoot,
+			block.Address,
+			block.PreviousAddress,
+			block.Memo,
+			strconv.Itoa(len(block.Evidence)),
+		})
+	})
+	if err != nil {
+		return count, err
+	}
+
+	writer.Flush()
+	return count, writer.Error()
+}
+
+// ExportEvidenceCSV writes the evidence of all the blocks of the chain in height order, one row for each source
+// with the block where it is included. It returns the number of rows written
+func (db *BlockChain) ExportEvidenceCSV(w io.Writer) (int, error) {
+
+	var count int
+	writer := csv.NewWriter(w)
+	if err := writer.Write(EvidenceCSVHeader); err != nil {
+		return count, err
+	}
+
+	err := db.Walk(func(block types.FullSignedBlock) error {
+		for position, source := range block.Evidence {
+			count++
+			err := writer.Write([]string{
+				block.Hash,
+				strconv.FormatUint(block.Height, 10),
+				strconv.FormatUint(block.Timestamp, 10),
+				strconv.Itoa(position),
+				source.CrawlerName,
+				source.Ticker,
+				strconv.FormatInt(source.Timestamp, 10),
+				source.Hash,
+				formatFloat(source.Data.LastPrice),
+				formatFloat(source.Data.Volume),
+				formatFloat(source.Data.QuoteVolume),
+				formatFloat(source.Data.HighPrice),
+				formatFloat(source.Data.OpenPrice),
+				formatFloat(source.Data.BidPrice),
+				formatFloat(source.Data.AskPrice),
+				source.Data.QuoteCurrency,
+				formatFloat(source.ConversionRate),
+				strconv.FormatBool(source.HasError),
+				source.Error,
+				strconv.FormatBool(source.Excluded),
+				source.ExclusionReason,
+				source.Data.DataURL,
+			})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return count, err
+	}
+
+	writer.Flush()
+	return count, writer.Error()
+}
+
+// ImportNDJSON reads blocks in height order, one json per line, and appends them to the chain. Each block is
+// verified like in Verify, and linked to the latest block of the chain, before being written. The import stops
+// at the first invalid block, returning a *VerificationError: the blocks already imported are kept, as they form
+// a valid chain. It returns the number of blocks imported
+func (db *BlockChain) ImportNDJSON(r io.Reader) (int, error) {
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.readOnly {
+		return 0, ErrReadOnly
+	}
+	var count int
+	decoder := json.NewDecoder(bufio.NewReader(r))
+
+	for {
+		var block types.FullSignedBlock
+		if err := decoder.Decode(&block); err == io.EOF {
+			return count, nil
+		} else if err != nil {
+			return count, err
+		}
+
+		if err := verifyBlock(block, db.latestBlock); err != nil {
+			return count, err
+		}
+		if err := db.kvstore.CommitBlock(block, LatestBlockKey); err != nil {
+			return count, err
+		}
+		db.latestBlock = &block
+		count++
+	}
+}
@@ -0,0 +1,122 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// Exports a chain as NDJSON
+func exportNDJSON(t *testing.T, chain *database.BlockChain) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	if _, err := chain.ExportNDJSON(&buffer); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+// Opens an empty chain in memory
+func newEmptyChain(t *testing.T) *database.BlockChain {
+	t.Helper()
+
+	chain, err := database.NewBlockChain("BTCUSD", "", database.WithMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chain.Close() })
+
+	return chain
+}
+
+func TestNDJSONRoundTrip(t *testing.T) {
+
+	blocks := newSignedBlocks(t, newSigner(t), 4)
+	chain, _ := chainOf(t, blocks)
+
+	var exported bytes.Buffer
+	count, err := chain.ExportNDJSON(&exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 || strings.Count(exported.String(), "\n") != 4 {
+		t.Fatalf("ExportNDJSON() wrote %d blocks in %d lines, expected 4", count, strings.Count(exported.String(), "\n"))
+	}
+
+	imported := newEmptyChain(t)
+	count, err = imported.ImportNDJSON(bytes.NewReader(exported.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if count != 4 {
+		t.Errorf("ImportNDJSON() imported %d blocks, expected 4", count)
+	}
+	if report, err := imported.Verify(); err != nil || report.LatestHash != blocks[3].Hash || report.Sources != 12 {
+		t.Errorf("Verify() of the imported chain = %+v, %v", report, err)
+	}
+	if again := exportNDJSON(t, imported); !bytes.Equal(again, exported.Bytes()) {
+		t.Errorf("the export of the imported chain is different:\n%s\nexpected:\n%s", again, exported.Bytes())
+	}
+
+	// The blocks are linked to the latest one of the chain: the same blocks can´t be imported twice
+	if _, err := imported.ImportNDJSON(bytes.NewReader(exported.Bytes())); err == nil {
+		t.Error("ImportNDJSON() imported the blocks twice")
+	}
+}
+
+func TestNDJSONTamperedLine(t *testing.T) {
+
+	blocks := newSignedBlocks(t, newSigner(t), 4)
+	chain, _ := chainOf(t, blocks)
+	lines := strings.SplitAfter(string(exportNDJSON(t, chain)), "\n")
+
+	// The price of the block 2 is changed in the export
+	var block types.FullSignedBlock
+	if err := json.Unmarshal([]byte(lines[2]), &block); err != nil {
+		t.Fatal(err)
+	}
+	block.AveragePrice = 1
+	line, err := json.Marshal(block)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines[2] = string(line) + "\n"
+
+	imported := newEmptyChain(t)
+	count, err := imported.ImportNDJSON(strings.NewReader(strings.Join(lines, "")))
+	var verificationErr *database.VerificationError
+	if !errors.As(err, &verificationErr) || verificationErr.Kind != database.BrokenBlockHash || verificationErr.Height != 2 {
+		t.Fatalf("ImportNDJSON() = %v, expected a broken block hash at the block 2", err)
+	}
+	if count != 2 {
+		t.Errorf("ImportNDJSON() imported %d blocks, expected 2", count)
+	}
+
+	// The blocks before the tampered one are kept
+	report, err := imported.Verify()
+	if err != nil || report.Blocks != 2 || report.LatestHash != blocks[1].Hash {
+		t.Errorf("Verify() of the imported chain = %+v, %v, expected the blocks 0 and 1", report, err)
+	}
+}
+
+func TestNDJSONInvalidLine(t *testing.T) {
+
+	blocks := newSignedBlocks(t, newSigner(t), 2)
+	chain, _ := chainOf(t, blocks)
+	content := string(exportNDJSON(t, chain)) + "{not json\n"
+
+	imported := newEmptyChain(t)
+	count, err := imported.ImportNDJSON(strings.NewReader(content))
+	if err == nil || count != 2 {
+		t.Errorf("ImportNDJSON() = %d, %v, expected 2 blocks and an error", count, err)
+	}
+}
@@ -1,8 +1,13 @@
 package database
 
 import (
+	"bytes"
 	"encoding/binary"
 	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"os"
 
 	"github.com/aquarelle-tech/darkmatter/types"
 	"github.com/dgraph-io/badger"
@@ -14,58 +19,263 @@ const (
 	TimestampKeyPrefix = 0x2
 	HeightKeyPrefix    = 0x3
 	FixedKeyPrefix     = 0xFF // Any other key
+
+	// IndexVersionKey is the key of the format of the indexes in the datastore
+	IndexVersionKey = "index-version"
+	// IndexVersion is the current format of the indexes: big-endian keys, and the timestamp keys include the height.
+	// The stores without version have little-endian keys, and they are reindexed when they are opened
+	IndexVersion = "2"
 )
 
-// Implements the KVStore interface
+// ErrNotFound is returned by the stores when a value or a block doesn´t exist
+var ErrNotFound = badger.ErrKeyNotFound
+
+// Implements the KVStore interface. The database is opened once and kept open until Close is called
 type Store struct {
-	StorFileLocation	string
+	StorFileLocation string
+
+	db *badger.DB
 }
 
-// Creates a new store for key-value pairs
-func NewKVStore (locationDirectory string) types.KVStore {
-	kvs := &Store {
-		StorFileLocation : locationDirectory,
+// Creates a new store for key-value pairs, opening (or creating) the database in the directory
+func NewKVStore(locationDirectory string) (*Store, error) {
+
+	if err := os.MkdirAll(locationDirectory, 0700); err != nil {
+		return nil, err
+	}
+
+	db, err := badger.Open(badger.DefaultOptions(locationDirectory))
+	if err != nil {
+		return nil, err
 	}
 
-	return kvs
+	store := &Store{
+		StorFileLocation: locationDirectory,
+		db:               db,
+	}
+	if err := store.migrateIndexes(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return store, nil
 }
 
-// Store a value in the database indexed by an uint64
-func storeUIntIndex (txn *badger.Txn, key uint64, value []byte, prefix byte) error {
+// OpenKVStoreReadOnly opens the database of an existing store without writing to it. The stores with indexes in an
+// older format can´t be read: they are migrated when they are opened with NewKVStore
+func OpenKVStoreReadOnly(locationDirectory string) (*Store, error) {
 
-	index := make([]byte, 8)
-	binary.LittleEndian.PutUint64(index, key)
-	index = append ([]byte{prefix}, index...)
+	if _, err := os.Stat(locationDirectory); err != nil {
+		return nil, err
+	}
 
-	return txn.Set(index, value)
+	db, err := badger.Open(badger.DefaultOptions(locationDirectory).WithReadOnly(true))
+	if err != nil {
+		return nil, err
+	}
+
+	store := &Store{
+		StorFileLocation: locationDirectory,
+		db:               db,
+	}
+	version, err := store.GetValue(IndexVersionKey)
+	if err != nil && err != badger.ErrKeyNotFound {
+		db.Close()
+		return nil, err
+	}
+	if string(version) != IndexVersion {
+		db.Close()
+		return nil, fmt.Errorf("the indexes of %s are in an older format, open it with the node to migrate them", locationDirectory)
+	}
+
+	return store, nil
 }
 
-// Read a value from the database indexed by an uint64
-func readUIntIndex (txn *badger.Txn, key uint64,  prefix byte) ([]byte, error) {
+// Rebuild the indexes of the blocks when they were written with an older format
+func (s *Store) migrateIndexes() error {
 
-	index := make([]byte, 8)
-	binary.LittleEndian.PutUint64(index, key)
-	index = append ([]byte{prefix}, index...)
+	version, err := s.GetValue(IndexVersionKey)
+	if err != nil && err != badger.ErrKeyNotFound {
+		return err
+	}
+	if string(version) == IndexVersion {
+		return nil
+	}
 
-	item, err := txn.Get(index)
+	var staleKeys [][]byte
+	var blocks []types.FullSignedBlock
+	err = s.db.View(func(txn *badger.Txn) error {
+		opts := badger.DefaultIteratorOptions
+		opts.PrefetchValues = false
+		it := txn.NewIterator(opts)
+		defer it.Close()
+
+		for it.Rewind(); it.Valid(); it.Next() {
+			item := it.Item()
+			switch item.Key()[0] {
+			case TimestampKeyPrefix, HeightKeyPrefix:
+				staleKeys = append(staleKeys, item.KeyCopy(nil))
+			case HashKeyPrefix:
+				var block types.FullSignedBlock
+				err := item.Value(func(value []byte) error {
+					return json.Unmarshal(value, &block)
+				})
+				if err != nil {
+					return err
+				}
+				blocks = append(blocks, block)
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	return item.ValueCopy(nil)
+	// The old keys are removed before writing the new ones, in the same batch
+	batch := s.db.NewWriteBatch()
+	if err := writeIndexes(batch, staleKeys, blocks); err != nil {
+		batch.Cancel()
+		return err
+	}
+	if err := batch.Flush(); err != nil {
+		return err
+	}
+
+	if len(blocks) > 0 {
+		log.Printf("Reindexed %d blocks in %s", len(blocks), s.StorFileLocation)
+	}
+
+	return nil
+}
+
+// Close releases the database. The store can´t be used after it
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// Write the batch for the reindex of the blocks: deletion of the stale keys, new indexes and version of the format
+func writeIndexes(batch *badger.WriteBatch, staleKeys [][]byte, blocks []types.FullSignedBlock) error {
+
+	for _, key := range staleKeys {
+		if err := batch.Delete(key); err != nil {
+			return err
+		}
+	}
+	for _, block := range blocks {
+		if err := batch.Set(timestampKey(block.Timestamp, block.Height), []byte(block.Hash)); err != nil {
+			return err
+		}
+		if err := batch.Set(heightKey(block.Height), []byte(block.Hash)); err != nil {
+			return err
+		}
+	}
+
+	return batch.Set(append([]byte{FixedKeyPrefix}, IndexVersionKey...), []byte(IndexVersion))
+}
+
+// Returns the key of an index by height: prefix + height, big-endian so the keys sort like the heights
+func heightKey(height uint64) []byte {
+
+	key := make([]byte, 9)
+	key[0] = HeightKeyPrefix
+	binary.BigEndian.PutUint64(key[1:], height)
+
+	return key
+}
+
+// Returns the key of an index by timestamp: prefix + timestamp + height, big-endian. The height keeps apart the
+// blocks with the same timestamp
+func timestampKey(timestamp uint64, height uint64) []byte {
+
+	key := make([]byte, 17)
+	key[0] = TimestampKeyPrefix
+	binary.BigEndian.PutUint64(key[1:], timestamp)
+	binary.BigEndian.PutUint64(key[9:], height)
+
+	return key
+}
+
+// Store the indexes of a block. The value of each index is the hash of the block
+func storeBlockIndexes(txn *badger.Txn, block types.FullSignedBlock) error {
+
+	if err := txn.Set(timestampKey(block.Timestamp, block.Height), []byte(block.Hash)); err != nil {
+		return err
+	}
+
+	return txn.Set(heightKey(block.Height), []byte(block.Hash))
+}
+
+// Read a block using the hash stored as the value of an index entry
+func readIndexedBlock(txn *badger.Txn, item *badger.Item) (types.FullSignedBlock, error) {
+
+	var block types.FullSignedBlock
+	hash, err := item.ValueCopy(nil)
+	if err != nil {
+		return block, err
+	}
+
+	bytes, err := readStringIndex(txn, string(hash), HashKeyPrefix)
+	if err != nil {
+		return block, err
+	}
+	err = json.Unmarshal(bytes, &block)
+
+	return block, err
+}
+
+// Walk an index from the key first to the key last (both included) and read the blocks, in the order of the keys
+// or in reverse order. A limit of 0 means no limit
+func scanIndex(txn *badger.Txn, prefix byte, first []byte, last []byte, limit int, reverse bool) ([]types.FullSignedBlock, error) {
+
+	var blocks []types.FullSignedBlock
+	if bytes.Compare(first, last) > 0 {
+		return blocks, nil
+	}
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	opts.Reverse = reverse
+	it := txn.NewIterator(opts)
+	defer it.Close()
+
+	start, end := first, last
+	if reverse {
+		start, end = last, first
+	}
+
+	for it.Seek(start); it.ValidForPrefix([]byte{prefix}); it.Next() {
+		item := it.Item()
+		if (!reverse && bytes.Compare(item.Key(), end) > 0) || (reverse && bytes.Compare(item.Key(), end) < 0) {
+			break
+		}
+
+		block, err := readIndexedBlock(txn, item)
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+
+		if limit > 0 && len(blocks) >= limit {
+			break
+		}
+	}
+
+	return blocks, nil
 }
 
 // Store a value in the database indexed by an uint64
-func storeStringIndex (txn *badger.Txn, key string, value []byte, prefix byte) error {
+func storeStringIndex(txn *badger.Txn, key string, value []byte, prefix byte) error {
 
-	index := append ([]byte{prefix}, []byte(key)...)
+	index := append([]byte{prefix}, []byte(key)...)
 	return txn.Set(index, value)
 }
 
 // Read a value from the database indexed by an uint64
-func readStringIndex (txn *badger.Txn, key string,  prefix byte) ([]byte, error) {
+func readStringIndex(txn *badger.Txn, key string, prefix byte) ([]byte, error) {
 
-	index := append ([]byte{prefix}, []byte(key)...)
+	index := append([]byte{prefix}, []byte(key)...)
 	item, err := txn.Get(index)
 	if err != nil {
 		return nil, err
@@ -74,79 +284,63 @@ func readStringIndex (txn *badger.Txn, key string,  prefix byte) ([]byte, error)
 	return item.ValueCopy(nil)
 }
 
-// Store a full block in the database. The block will be indexed by their timestamp and Height
-func (s Store) StoreBlock (block types.FullSignedBlock) error {
+// Write a block (serialized in bytes) and its indexes. There can´t be two blocks with the same height
+func storeBlock(txn *badger.Txn, block types.FullSignedBlock, bytes []byte) error {
 
-	// Open badger
-	stor, err := badger.Open(badger.DefaultOptions(s.StorFileLocation))
-	if err != nil {
-		panic(err)
+	if _, err := txn.Get(heightKey(block.Height)); err == nil {
+		return fmt.Errorf("there is already a block with height %d", block.Height)
+	} else if err != badger.ErrKeyNotFound {
+		return err
 	}
 
-	defer stor.Close()
-
-	// Serialize all the parts: block in json
-	bytes, err := json.Marshal(block)
+	// Store the hash as a key. This is the main register
+	if err := storeStringIndex(txn, block.Hash, bytes, HashKeyPrefix); err != nil {
+		return err
+	}
 
-	err = stor.Update(func(txn *badger.Txn) error {
+	// And now store the indexes. Using this indexes it is possible to retrieve the hash, and next the block
+	return storeBlockIndexes(txn, block)
+}
 
-		var txErr error
-		// Store the hash as a key. This is the main register
-		if txErr = storeStringIndex(txn, block.Hash, bytes, HashKeyPrefix); txErr == nil {
-			// And now store the indexes. Using this indexes it is possible to retrieve the hash, and next the block
-			if txErr = storeUIntIndex(txn, block.Timestamp, []byte(block.Hash), TimestampKeyPrefix); txErr != nil { // By timestamp
-				return txErr
-			}
+// Store a full block in the database. The block will be indexed by their timestamp and Height
+func (s *Store) StoreBlock(block types.FullSignedBlock) error {
 
-			if txErr = storeUIntIndex(txn, block.Height, []byte(block.Hash), HeightKeyPrefix); txErr != nil { // By block Height
-				return txErr
-			}
-		} 
+	// Serialize all the parts: block in json
+	bytes, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
 
-		 return txErr
+	return s.db.Update(func(txn *badger.Txn) error {
+		return storeBlock(txn, block, bytes)
 	})
-
-	return err
 }
 
-// Read a block from the database using their hash
-func (s Store) GetBlock (hash string) (*types.FullSignedBlock, error) {
-	// Open badger
-	stor, err := badger.Open(badger.DefaultOptions(s.StorFileLocation))
+// CommitBlock stores a block with its indexes, and the block as the value of latestKey, all of it in the same
+// transaction. If something fails, nothing is written
+func (s *Store) CommitBlock(block types.FullSignedBlock, latestKey string) error {
+
+	bytes, err := json.Marshal(block)
 	if err != nil {
-		panic(err)
+		return err
 	}
 
-	defer stor.Close()
-
-	var block types.FullSignedBlock
-	err = stor.Update(func(txn *badger.Txn) error {
-		bytes, err := readStringIndex (txn, hash, HashKeyPrefix)
-		if err != nil{
+	return s.db.Update(func(txn *badger.Txn) error {
+		if err := storeBlock(txn, block, bytes); err != nil {
 			return err
 		}
-		err = json.Unmarshal(bytes, &block)
 
-		return err
+		return storeStringIndex(txn, latestKey, bytes, FixedKeyPrefix)
 	})
-
-	return &block, err
 }
 
-// Read a block from the database using their timestamp as index
-func (s Store) FindBlockByTimestamp (timestamp uint64) (*types.FullSignedBlock, error) {
-	// Open badger
-	stor, err := badger.Open(badger.DefaultOptions(s.StorFileLocation))
-	if err != nil {
-		panic(err)
-	}
-
-	defer stor.Close()
+// Read a block from the database using their hash
+func (s *Store) GetBlock(hash string) (*types.FullSignedBlock, error) {
 
 	var block types.FullSignedBlock
-	err = stor.Update(func(txn *badger.Txn) error {
-		bytes, err := readUIntIndex (txn, timestamp, TimestampKeyPrefix)
-		if err != nil{
+	err := s.db.View(func(txn *badger.Txn) error {
+		bytes, err := readStringIndex(txn, hash, HashKeyPrefix)
+		if err != nil {
 			return err
 		}
 		err = json.Unmarshal(bytes, &block)
@@ -157,24 +351,31 @@ func (s Store) FindBlockByTimestamp (timestamp uint64) (*types.FullSignedBlock,
 	return &block, err
 }
 
+// Read a block from the database using their timestamp as index. If several blocks have the same timestamp,
+// the lowest one is returned
+func (s *Store) FindBlockByTimestamp(timestamp uint64) (*types.FullSignedBlock, error) {
 
-// Read a block from the database using their timestamp as index
-func (s Store) FindBlockByHeight (Height uint64) (*types.FullSignedBlock, error) {
-	// Open badger
-	stor, err := badger.Open(badger.DefaultOptions(s.StorFileLocation))
+	blocks, err := s.BlocksByTimestamp(timestamp, timestamp, 1, false)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	if len(blocks) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &blocks[0], nil
+}
 
-	defer stor.Close()
+// Read a block from the database using their height as index
+func (s *Store) FindBlockByHeight(height uint64) (*types.FullSignedBlock, error) {
 
 	var block types.FullSignedBlock
-	err = stor.Update(func(txn *badger.Txn) error {
-		bytes, err := readUIntIndex (txn, Height, HeightKeyPrefix)
-		if err != nil{
+	err := s.db.View(func(txn *badger.Txn) error {
+		item, err := txn.Get(heightKey(height))
+		if err != nil {
 			return err
 		}
-		err = json.Unmarshal(bytes, &block)
+		block, err = readIndexedBlock(txn, item)
 
 		return err
 	})
@@ -182,39 +383,51 @@ func (s Store) FindBlockByHeight (Height uint64) (*types.FullSignedBlock, error)
 	return &block, err
 }
 
+// BlocksByHeight returns the blocks with a height between from and to (both included), ordered by height, or
+// in reverse order. A limit of 0 means no limit
+func (s *Store) BlocksByHeight(from uint64, to uint64, limit int, reverse bool) ([]types.FullSignedBlock, error) {
 
-// StoreValue stores an abritrary value in the database, indexed by a string 
-func (s Store) StoreValue (key string, value []byte) error {
+	var blocks []types.FullSignedBlock
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		blocks, err = scanIndex(txn, HeightKeyPrefix, heightKey(from), heightKey(to), limit, reverse)
 
-	// Open badger
-	stor, err := badger.Open(badger.DefaultOptions(s.StorFileLocation))
-	if err != nil {
-		panic(err)
-	}
+		return err
+	})
+
+	return blocks, err
+}
+
+// BlocksByTimestamp returns the blocks with a timestamp between from and to (both included), ordered by time, or
+// in reverse order. A limit of 0 means no limit
+func (s *Store) BlocksByTimestamp(from uint64, to uint64, limit int, reverse bool) ([]types.FullSignedBlock, error) {
 
-	defer stor.Close()
+	var blocks []types.FullSignedBlock
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		blocks, err = scanIndex(txn, TimestampKeyPrefix, timestampKey(from, 0), timestampKey(to, math.MaxUint64), limit, reverse)
 
-	err = stor.Update(func(txn *badger.Txn) error {
-		return storeStringIndex (txn, key, value, FixedKeyPrefix);
+		return err
 	})
 
-	return err
+	return blocks, err
 }
 
-// GetValue returns a value stored in the database indexed by an string
-func (s *Store) GetValue (key string) ([]byte, error) {
+// StoreValue stores an abritrary value in the database, indexed by a string
+func (s *Store) StoreValue(key string, value []byte) error {
 
-	// Open badger
-	stor, err := badger.Open(badger.DefaultOptions(s.StorFileLocation))
-	if err != nil {
-		panic(err)
-	}
+	return s.db.Update(func(txn *badger.Txn) error {
+		return storeStringIndex(txn, key, value, FixedKeyPrefix)
+	})
+}
 
-	defer stor.Close()
+// GetValue returns a value stored in the database indexed by an string
+func (s *Store) GetValue(key string) ([]byte, error) {
 
 	var bytes []byte
-	err = stor.Update(func(txn *badger.Txn) error {
-		bytes, err = readStringIndex (txn, key, FixedKeyPrefix);
+	err := s.db.View(func(txn *badger.Txn) error {
+		var err error
+		bytes, err = readStringIndex(txn, key, FixedKeyPrefix)
 
 		return err
 	})
@@ -0,0 +1,151 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database_test
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/database/storetest"
+	"github.com/aquarelle-tech/darkmatter/types"
+	"github.com/dgraph-io/badger"
+)
+
+func TestKVStore(t *testing.T) {
+	newStore := func() (types.KVStore, error) {
+		return database.NewKVStore(t.TempDir())
+	}
+	if err := storetest.TestKVStore(newStore); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Writes a chain with the keys of the baseline store: little-endian heights and timestamps, without index version
+func writeBaselineChain(t *testing.T, directory string, length int) []types.FullSignedBlock {
+	t.Helper()
+
+	db, err := badger.Open(badger.DefaultOptions(directory))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	littleEndianKey := func(prefix byte, value uint64) []byte {
+		key := make([]byte, 9)
+		key[0] = prefix
+		binary.LittleEndian.PutUint64(key[1:], value)
+		return key
+	}
+
+	var chain []types.FullSignedBlock
+	for height := 0; height < length; height++ {
+		block := types.FullSignedBlock{
+			Height:       uint64(height),
+			Timestamp:    uint64(1573257600 + 5*height),
+			AveragePrice: 8750 + float64(height),
+			Ticker:       "BTCUSD",
+		}
+		if height > 0 {
+			block.PreviousHash = chain[height-1].Hash
+		}
+		if err := block.CreateHash(); err != nil {
+			t.Fatal(err)
+		}
+		chain = append(chain, block)
+
+		bytes, err := json.Marshal(block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.Update(func(txn *badger.Txn) error {
+			if err := txn.Set(append([]byte{database.HashKeyPrefix}, block.Hash...), bytes); err != nil {
+				return err
+			}
+			if err := txn.Set(littleEndianKey(database.TimestampKeyPrefix, block.Timestamp), []byte(block.Hash)); err != nil {
+				return err
+			}
+			if err := txn.Set(littleEndianKey(database.HeightKeyPrefix, block.Height), []byte(block.Hash)); err != nil {
+				return err
+			}
+			return txn.Set(append([]byte{database.FixedKeyPrefix}, database.LatestBlockKey...), bytes)
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return chain
+}
+
+// The stores of the baseline are reindexed with big-endian keys when the chain is opened
+func TestKVStoreIndexMigration(t *testing.T) {
+
+	directory := t.TempDir()
+	// More than 256 blocks, so the little-endian order differs from the order of the heights
+	chain := writeBaselineChain(t, directory, 300)
+
+	blockchain, err := database.NewBlockChain("BTCUSD", directory)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer blockchain.Close()
+
+	for _, height := range []uint64{0, 1, 255, 256, 299} {
+		block, err := blockchain.GetBlockByHeight(height)
+		if err != nil {
+			t.Fatalf("GetBlockByHeight(%d): %v", height, err)
+		}
+		if block.Hash != chain[height].Hash {
+			t.Errorf("GetBlockByHeight(%d) returned the block %d", height, block.Height)
+		}
+	}
+	block, err := blockchain.GetBlockByTimestamp(int64(chain[256].Timestamp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Hash != chain[256].Hash {
+		t.Errorf("GetBlockByTimestamp returned the block %d, want 256", block.Height)
+	}
+
+	// The little-endian key of the height 256 is the big-endian key of 2^48: the old keys must be gone
+	if _, err := blockchain.GetBlockByHeight(1 << 48); err == nil {
+		t.Error("a stale little-endian key is still indexed")
+	}
+
+	var walked []uint64
+	err = blockchain.Walk(func(block types.FullSignedBlock) error {
+		walked = append(walked, block.Height)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(walked) != len(chain) {
+		t.Fatalf("walked %d blocks, want %d", len(walked), len(chain))
+	}
+	for i, height := range walked {
+		if height != uint64(i) {
+			t.Fatalf("the block %d of the walk has the height %d", i, height)
+		}
+	}
+
+	blocks, err := blockchain.BlocksBetweenHeights(250, 260)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(blocks) != 11 || blocks[0].Height != 250 || blocks[10].Height != 260 {
+		t.Errorf("BlocksBetweenHeights(250, 260) returned %d blocks", len(blocks))
+	}
+
+	// The chain goes on from the latest block
+	next, err := blockchain.NewFullSignedBlock("BTCUSD", chain[299].Timestamp+5, 9100, 1, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next.Height != 300 || next.PreviousHash != chain[299].Hash {
+		t.Errorf("the new block has the height %d after %s, want 300 after the block 299", next.Height, next.PreviousHash)
+	}
+}
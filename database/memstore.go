@@ -0,0 +1,275 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// ErrStoreClosed is returned by a MemoryStore used after Close
+var ErrStoreClosed = errors.New("the store is closed")
+
+// Entry of the index by timestamp of a MemoryStore
+type timestampEntry struct {
+	timestamp uint64
+	height    uint64
+	hash      string
+}
+
+// Sorted as the keys of the Badger index: by timestamp, then by height
+func (e timestampEntry) less(other timestampEntry) bool {
+	if e.timestamp != other.timestamp {
+		return e.timestamp < other.timestamp
+	}
+	return e.height < other.height
+}
+
+// MemoryStore implements the KVStore interface keeping everything in memory. It has the same semantics as the
+// Badger store, so it can be used for tests and for nodes without persistence. The blocks are kept serialized,
+// so the stored blocks can´t be changed through the values returned
+type MemoryStore struct {
+	mutex      sync.RWMutex
+	closed     bool
+	values     map[string][]byte
+	blocks     map[string][]byte // Indexed by hash
+	heights    []uint64          // Sorted
+	byHeight   map[uint64]string
+	timestamps []timestampEntry // Sorted by timestamp and height
+}
+
+// NewMemoryStore creates an empty store in memory
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		values:   make(map[string][]byte),
+		blocks:   make(map[string][]byte),
+		byHeight: make(map[uint64]string),
+	}
+}
+
+// Close releases the contents of the store. The store can´t be used after it
+func (s *MemoryStore) Close() error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.closed = true
+	s.values, s.blocks, s.byHeight = nil, nil, nil
+	s.heights, s.timestamps = nil, nil
+
+	return nil
+}
+
+// StoreValue stores an abritrary value, indexed by a string
+func (s *MemoryStore) StoreValue(key string, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+	s.values[key] = append([]byte(nil), value...)
+
+	return nil
+}
+
+// GetValue returns a value indexed by an string
+func (s *MemoryStore) GetValue(key string) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+	value, ok := s.values[key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return append([]byte(nil), value...), nil
+}
+
+// Add a block and its indexes. The caller must hold the lock
+func (s *MemoryStore) storeBlock(block types.FullSignedBlock, bytes []byte) error {
+
+	if s.closed {
+		return ErrStoreClosed
+	}
+	if _, exists := s.byHeight[block.Height]; exists {
+		return fmt.Errorf("there is already a block with height %d", block.Height)
+	}
+
+	s.blocks[block.Hash] = bytes
+	s.byHeight[block.Height] = block.Hash
+
+	i := sort.Search(len(s.heights), func(i int) bool { return s.heights[i] >= block.Height })
+	s.heights = append(s.heights, 0)
+	copy(s.heights[i+1:], s.heights[i:])
+	s.heights[i] = block.Height
+
+	entry := timestampEntry{timestamp: block.Timestamp, height: block.Height, hash: block.Hash}
+	j := sort.Search(len(s.timestamps), func(j int) bool { return !s.timestamps[j].less(entry) })
+	s.timestamps = append(s.timestamps, timestampEntry{})
+	copy(s.timestamps[j+1:], s.timestamps[j:])
+	s.timestamps[j] = entry
+
+	return nil
+}
+
+// StoreBlock stores a block, indexed by their hash, timestamp and height
+func (s *MemoryStore) StoreBlock(block types.FullSignedBlock) error {
+
+	bytes, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	return s.storeBlock(block, bytes)
+}
+
+// CommitBlock stores a block with its indexes, and the block as the value of latestKey. If something fails,
+// nothing is written
+func (s *MemoryStore) CommitBlock(block types.FullSignedBlock, latestKey string) error {
+
+	bytes, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if err := s.storeBlock(block, bytes); err != nil {
+		return err
+	}
+	s.values[latestKey] = append([]byte(nil), bytes...)
+
+	return nil
+}
+
+// Read a stored block. The caller must hold the lock
+func (s *MemoryStore) readBlock(hash string) (types.FullSignedBlock, error) {
+
+	var block types.FullSignedBlock
+	bytes, ok := s.blocks[hash]
+	if !ok {
+		return block, ErrNotFound
+	}
+	err := json.Unmarshal(bytes, &block)
+
+	return block, err
+}
+
+// GetBlock reads a block using their hash
+func (s *MemoryStore) GetBlock(hash string) (*types.FullSignedBlock, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+	block, err := s.readBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
+// FindBlockByTimestamp reads a block using their timestamp. If several blocks have the same timestamp, the lowest
+// one is returned
+func (s *MemoryStore) FindBlockByTimestamp(timestamp uint64) (*types.FullSignedBlock, error) {
+
+	blocks, err := s.BlocksByTimestamp(timestamp, timestamp, 1, false)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &blocks[0], nil
+}
+
+// FindBlockByHeight reads a block using their height
+func (s *MemoryStore) FindBlockByHeight(height uint64) (*types.FullSignedBlock, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+	hash, ok := s.byHeight[height]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	block, err := s.readBlock(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return &block, nil
+}
+
+// Read the blocks of the positions first to last (both included) of an index, in order or in reverse order.
+// The caller must hold the lock
+func (s *MemoryStore) scan(first int, last int, limit int, reverse bool, hash func(i int) string) ([]types.FullSignedBlock, error) {
+
+	var blocks []types.FullSignedBlock
+	for n := 0; n <= last-first; n++ {
+		i := first + n
+		if reverse {
+			i = last - n
+		}
+
+		block, err := s.readBlock(hash(i))
+		if err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+
+		if limit > 0 && len(blocks) >= limit {
+			break
+		}
+	}
+
+	return blocks, nil
+}
+
+// BlocksByHeight returns the blocks with a height between from and to (both included), ordered by height, or
+// in reverse order. A limit of 0 means no limit
+func (s *MemoryStore) BlocksByHeight(from uint64, to uint64, limit int, reverse bool) ([]types.FullSignedBlock, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+	first := sort.Search(len(s.heights), func(i int) bool { return s.heights[i] >= from })
+	last := sort.Search(len(s.heights), func(i int) bool { return s.heights[i] > to }) - 1
+
+	return s.scan(first, last, limit, reverse, func(i int) string { return s.byHeight[s.heights[i]] })
+}
+
+// BlocksByTimestamp returns the blocks with a timestamp between from and to (both included), ordered by time, or
+// in reverse order. A limit of 0 means no limit
+func (s *MemoryStore) BlocksByTimestamp(from uint64, to uint64, limit int, reverse bool) ([]types.FullSignedBlock, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	if s.closed {
+		return nil, ErrStoreClosed
+	}
+	first := sort.Search(len(s.timestamps), func(i int) bool { return s.timestamps[i].timestamp >= from })
+	last := sort.Search(len(s.timestamps), func(i int) bool { return s.timestamps[i].timestamp > to }) - 1
+
+	return s.scan(first, last, limit, reverse, func(i int) string { return s.timestamps[i].hash })
+}
@@ -0,0 +1,21 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database_test
+
+import (
+	"testing"
+
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/database/storetest"
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+func TestMemoryStore(t *testing.T) {
+	newStore := func() (types.KVStore, error) {
+		return database.NewMemoryStore(), nil
+	}
+	if err := storetest.TestKVStore(newStore); err != nil {
+		t.Fatal(err)
+	}
+}
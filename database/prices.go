@@ -0,0 +1,117 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database
+
+import (
+	"errors"
+	"math"
+	"time"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+var (
+	// ErrNoPrice is returned when there is no block to answer a price query
+	ErrNoPrice = errors.New("there is no price for the requested time")
+	// ErrStalePrice is returned when the block found is farther from the requested time than the maximum staleness
+	ErrStalePrice = errors.New("the price found is stale")
+)
+
+// Number of blocks read at once to find a block with a price
+const priceScanSize = 4
+
+// PricePoint is the answer to a price query: the block used and its distance to the requested time
+type PricePoint struct {
+	Ticker    string                 `json:"ticker"`
+	Price     float64                `json:"price"`
+	Requested int64                  `json:"requested"` // Requested time (Unix seconds)
+	Timestamp uint64                 `json:"timestamp"` // Timestamp of the block
+	Offset    int64                  `json:"offset"`    // Seconds from the requested time to the block. Negative when the block is older
+	Staleness int64                  `json:"staleness"` // Seconds between the block and the requested time, always positive
+	Stale     bool                   `json:"stale"`
+	Block     *types.FullSignedBlock `json:"block"`
+}
+
+// Creates the answer of a price query from a block
+func newPricePoint(block types.FullSignedBlock, requested int64, maxStaleness time.Duration) PricePoint {
+
+	offset := int64(block.Timestamp) - requested
+	point := PricePoint{
+		Ticker:    block.Ticker,
+		Price:     block.AveragePrice,
+		Requested: requested,
+		Timestamp: block.Timestamp,
+		Offset:    offset,
+		Staleness: int64(math.Abs(float64(offset))),
+		Block:     &block,
+	}
+	point.Stale = maxStaleness > 0 && time.Duration(point.Staleness)*time.Second > maxStaleness
+
+	return point
+}
+
+// PriceAt returns the price of the latest block at or before the time t. If nearest is true, the block closest to t
+// is used instead, before or after (the older one on a tie). With a maxStaleness greater than 0, a block farther
+// than it from t is returned with ErrStalePrice
+func (db *BlockChain) PriceAt(t time.Time, nearest bool, maxStaleness time.Duration) (PricePoint, error) {
+
+	requested := t.Unix()
+	var candidates []types.FullSignedBlock
+
+	if requested >= 0 {
+		before, err := db.firstPriceBlock(0, uint64(requested), true)
+		if err != nil {
+			return PricePoint{}, err
+		}
+		candidates = append(candidates, before...)
+	}
+	if nearest && requested < math.MaxInt64 {
+		from := uint64(0)
+		if requested >= 0 {
+			from = uint64(requested) + 1
+		}
+		after, err := db.firstPriceBlock(from, math.MaxUint64, false)
+		if err != nil {
+			return PricePoint{}, err
+		}
+		candidates = append(candidates, after...)
+	}
+
+	if len(candidates) == 0 {
+		return PricePoint{}, ErrNoPrice
+	}
+
+	point := newPricePoint(candidates[0], requested, maxStaleness)
+	if len(candidates) > 1 {
+		if other := newPricePoint(candidates[1], requested, maxStaleness); other.Staleness < point.Staleness {
+			point = other
+		}
+	}
+	if point.Stale {
+		return point, ErrStalePrice
+	}
+
+	return point, nil
+}
+
+// Returns the first block with a price in a range of timestamps, or none. The key rotation blocks have no price,
+// and they share the timestamp of the block before them, so the range is read again with a larger limit until a
+// block with a price is found
+func (db *BlockChain) firstPriceBlock(from uint64, to uint64, reverse bool) ([]types.FullSignedBlock, error) {
+
+	for limit := priceScanSize; ; limit *= 2 {
+		blocks, err := db.kvstore.BlocksByTimestamp(from, to, limit, reverse)
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range blocks {
+			if !block.IsKeyRotation() {
+				return []types.FullSignedBlock{block}, nil
+			}
+		}
+		if len(blocks) < limit {
+			return nil, nil
+		}
+	}
+}
@@ -0,0 +1,129 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/aquarelle-tech/darkmatter/database"
+)
+
+// Creates a chain in memory with a block for each timestamp. The price of a block is its timestamp divided by 10
+func newPriceChain(t *testing.T, timestamps ...uint64) *database.BlockChain {
+	t.Helper()
+
+	chain, err := database.NewBlockChain("BTCUSD", "", database.WithMemoryStore(), database.WithSigner(newSigner(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chain.Close() })
+
+	for _, timestamp := range timestamps {
+		if _, err := chain.NewFullSignedBlock("BTCUSD", timestamp, float64(timestamp)/10, 1, nil, ""); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return chain
+}
+
+func TestPriceAt(t *testing.T) {
+
+	chain := newPriceChain(t, 1000, 1010, 1020)
+	tests := []struct {
+		name         string
+		requested    int64
+		nearest      bool
+		maxStaleness time.Duration
+		price        float64
+		offset       int64
+		err          error
+	}{
+		{name: "before the first block", requested: 990, err: database.ErrNoPrice},
+		{name: "nearest before the first block", requested: 990, nearest: true, price: 100, offset: 10},
+		{name: "at a block", requested: 1010, price: 101, offset: 0},
+		{name: "between blocks", requested: 1017, price: 101, offset: -7},
+		{name: "nearest between blocks", requested: 1017, nearest: true, price: 102, offset: 3},
+		{name: "nearest in the middle", requested: 1015, nearest: true, price: 101, offset: -5},
+		{name: "after the last block", requested: 1080, price: 102, offset: -60},
+		{name: "within the staleness", requested: 1080, maxStaleness: time.Minute, price: 102, offset: -60},
+		{name: "stale", requested: 1081, maxStaleness: time.Minute, price: 102, offset: -61, err: database.ErrStalePrice},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			point, err := chain.PriceAt(time.Unix(test.requested, 0), test.nearest, test.maxStaleness)
+			if err != test.err {
+				t.Fatalf("PriceAt() = %v, expected %v", err, test.err)
+			}
+			if test.err == database.ErrNoPrice {
+				return
+			}
+			if point.Price != test.price || point.Offset != test.offset || point.Requested != test.requested {
+				t.Errorf("PriceAt() = %v at %d, requested %d, expected %v at %d", point.Price, point.Offset, point.Requested, test.price, test.offset)
+			}
+			if point.Staleness < 0 || point.Staleness != point.Offset && point.Staleness != -point.Offset {
+				t.Errorf("the staleness %d doesn´t match the offset %d", point.Staleness, point.Offset)
+			}
+			if point.Stale != (test.err == database.ErrStalePrice) {
+				t.Errorf("the point is stale: %v", point.Stale)
+			}
+		})
+	}
+
+	empty := newPriceChain(t)
+	if _, err := empty.PriceAt(time.Unix(1000, 0), true, 0); err != database.ErrNoPrice {
+		t.Errorf("PriceAt() of an empty chain = %v, expected %v", err, database.ErrNoPrice)
+	}
+}
+
+// The rotation blocks share the timestamp of the block before them, and they have no price
+func TestPriceAtRotation(t *testing.T) {
+
+	chain := newPriceChain(t, 1000, 1010)
+	// More rotations than the blocks read at once, so the scan needs more than one read
+	for i := 0; i < 5; i++ {
+		if _, err := chain.RotateSigner(newSigner(t)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := chain.NewFullSignedBlock("BTCUSD", 1030, 103, 1, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		requested int64
+		nearest   bool
+		price     float64
+		height    uint64
+	}{
+		{name: "at the rotations", requested: 1010, price: 101, height: 1},
+		{name: "after the rotations", requested: 1020, price: 101, height: 1},
+		{name: "nearest after the rotations", requested: 1021, nearest: true, price: 103, height: 7},
+		{name: "after the new key", requested: 1030, price: 103, height: 7},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			point, err := chain.PriceAt(time.Unix(test.requested, 0), test.nearest, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if point.Price != test.price || point.Block.Height != test.height || point.Block.IsKeyRotation() {
+				t.Errorf("PriceAt() = %v of the block %d, expected %v of the block %d", point.Price, point.Block.Height, test.price, test.height)
+			}
+		})
+	}
+
+	// A chain with only rotations after its first block
+	rotated := newPriceChain(t, 1000)
+	if _, err := rotated.RotateSigner(newSigner(t)); err != nil {
+		t.Fatal(err)
+	}
+	if point, err := rotated.PriceAt(time.Unix(999, 0), true, 0); err != nil || point.Block.Height != 0 {
+		t.Errorf("PriceAt() = %v, %v, expected the block 0", point.Block, err)
+	}
+	if _, err := rotated.PriceAt(time.Unix(999, 0), false, 0); err != database.ErrNoPrice {
+		t.Errorf("PriceAt() = %v, expected %v", err, database.ErrNoPrice)
+	}
+}
@@ -0,0 +1,71 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database
+
+import (
+	"errors"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// ErrNoMerkleRoot is returned when a proof is requested for a block whose hash doesn´t commit to a Merkle root: the
+// blocks created before the Merkle roots, and the legacy hashes, which cover the whole evidence instead of the root
+var ErrNoMerkleRoot = errors.New("the block doesn´t have a Merkle root")
+
+// EvidenceProof proves that the quote of a source is part of the evidence of a block, without the rest of the
+// evidence: the hash of the source must be the leaf of the proof, and its root the Merkle root of the block. The
+// block is sent without evidence. Its canonical hash covers the Merkle root, so it can be verified without it
+type EvidenceProof struct {
+	Block  types.FullSignedBlock `json:"block"`
+	Source types.Result          `json:"source"`
+	Proof  types.MerkleProof     `json:"proof"`
+}
+
+// Verify checks the hashes of the block and of the source, and that the source is included in the Merkle root
+// of the block
+func (p EvidenceProof) Verify() bool {
+	if p.Block.HashVersion < types.CanonicalHashVersion || p.Block.VerifyHash() != nil || p.Source.VerifyHash() != nil {
+		return false
+	}
+
+	return p.Proof.Leaf == p.Source.Hash && p.Proof.Root == p.Block.MerkleRoot && p.Proof.Verify()
+}
+
+// EvidenceProof returns the proof of inclusion of the source (by the name of the crawler) in the block at a height
+func (db *BlockChain) EvidenceProof(height uint64, source string) (EvidenceProof, error) {
+
+	block, err := db.kvstore.FindBlockByHeight(height)
+	if err != nil {
+		return EvidenceProof{}, err
+	}
+	if block.MerkleRoot == "" || block.HashVersion < types.CanonicalHashVersion {
+		return EvidenceProof{}, ErrNoMerkleRoot
+	}
+
+	leaves := make([]string, len(block.Evidence))
+	index := -1
+	for i, result := range block.Evidence {
+		leaves[i] = result.Hash
+		if index < 0 && result.CrawlerName == source {
+			index = i
+		}
+	}
+	if index < 0 {
+		return EvidenceProof{}, ErrNotFound
+	}
+
+	proof, err := types.NewMerkleProof(leaves, index)
+	if err != nil {
+		return EvidenceProof{}, err
+	}
+
+	result := block.Evidence[index]
+	block.Evidence = nil
+
+	return EvidenceProof{
+		Block:  *block,
+		Source: result,
+		Proof:  proof,
+	}, nil
+}
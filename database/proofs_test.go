@@ -0,0 +1,88 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database_test
+
+import (
+	"testing"
+
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+func TestEvidenceProof(t *testing.T) {
+
+	sources := []string{"binance", "bitfinex", "bitstamp", "coinbase", "gemini"}
+	chain, err := database.NewBlockChain("BTCUSD", "", database.WithMemoryStore(), database.WithSigner(newSigner(t)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	// Blocks with odd numbers of sources, and a block without evidence
+	for i, count := range []int{1, 3, 5, 0} {
+		timestamp := 1583020800 + int64(i)*5
+		evidence := newEvidence(t, timestamp, 8750, sources[:count]...)
+		if _, err := chain.NewFullSignedBlock("BTCUSD", uint64(timestamp), 8750, 10, evidence, ""); err != nil {
+			t.Fatal(err)
+		}
+
+		for _, source := range sources[:count] {
+			proof, err := chain.EvidenceProof(uint64(i), source)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if proof.Source.CrawlerName != source || len(proof.Block.Evidence) != 0 || !proof.Verify() {
+				t.Errorf("invalid proof of %s in the block %d of %d sources: %+v", source, i, count, proof)
+			}
+
+			tampered := proof
+			tampered.Source.Data.LastPrice++
+			if err := tampered.Source.CreateHash(); err != nil {
+				t.Fatal(err)
+			}
+			if tampered.Verify() {
+				t.Errorf("the proof of %s in the block %d verifies another quote", source, i)
+			}
+		}
+	}
+
+	if _, err := chain.EvidenceProof(1, "kraken"); err != database.ErrNotFound {
+		t.Errorf("EvidenceProof() of a missing source = %v, expected %v", err, database.ErrNotFound)
+	}
+	if _, err := chain.EvidenceProof(3, "binance"); err != database.ErrNoMerkleRoot {
+		t.Errorf("EvidenceProof() of a block without evidence = %v, expected %v", err, database.ErrNoMerkleRoot)
+	}
+}
+
+// The legacy hashes cover the whole evidence, not the Merkle root: a root in a legacy block proves nothing
+func TestEvidenceProofLegacy(t *testing.T) {
+
+	evidence := newEvidence(t, 1583020800, 8750, "binance", "coinbase", "kraken")
+	block := types.FullSignedBlock{
+		Timestamp:     1583020800,
+		AveragePrice:  8750,
+		AverageVolume: 10,
+		Ticker:        "BTCUSD",
+		MerkleRoot:    types.EvidenceRoot(evidence),
+		Evidence:      evidence,
+	}
+	signWithVersion(t, &block, newSigner(t), types.LegacyHashVersion)
+	chain, _ := chainOf(t, []types.FullSignedBlock{block})
+
+	if _, err := chain.EvidenceProof(0, "binance"); err != database.ErrNoMerkleRoot {
+		t.Errorf("EvidenceProof() of a legacy block = %v, expected %v", err, database.ErrNoMerkleRoot)
+	}
+
+	// A proof built by hand for the legacy block doesn´t verify either
+	leaves := []string{evidence[0].Hash, evidence[1].Hash, evidence[2].Hash}
+	merkleProof, err := types.NewMerkleProof(leaves, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Evidence = nil
+	proof := database.EvidenceProof{Block: block, Source: evidence[0], Proof: merkleProof}
+	if proof.Verify() {
+		t.Error("the proof of a legacy block verifies")
+	}
+}
@@ -0,0 +1,78 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database
+
+import (
+	"errors"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// RotationMemo is the memo of the blocks that rotate the key of the node
+const RotationMemo = types.RotationMemo
+
+var (
+	// ErrUnexpectedSigner is returned when a block would be signed by a key other than the one announced by the
+	// latest block of the chain
+	ErrUnexpectedSigner = errors.New("the signer is not the key announced by the chain")
+	// ErrNoSigner is returned when a key rotation is requested for a chain without signer
+	ErrNoSigner = errors.New("the chain has no signer")
+)
+
+// KeyPeriod is the range of heights signed by a key
+type KeyPeriod struct {
+	Address string `json:"address"` // Empty for the blocks created without the identity of a node
+	From    uint64 `json:"from"`
+	To      uint64 `json:"to"` // Inclusive. The rotation block is the last one of the period of the old key
+}
+
+// RotateSigner replaces the key that signs the blocks of the chain. A rotation block, signed by the current key,
+// announces the address of the next one in its NextAddress. The rotation block has no price nor evidence, and it
+// has the timestamp of the latest block, so it never looks like a new price. When the latest block already
+// announces the next key, i.e. a rotation was interrupted, the signer is replaced without writing another block
+func (db *BlockChain) RotateSigner(next types.BlockSigner) (*types.FullSignedBlock, error) {
+
+	db.mutex.Lock()
+	defer db.mutex.Unlock()
+
+	if db.signer == nil {
+		return nil, ErrNoSigner
+	}
+	// An empty chain starts directly with the next key
+	if db.latestBlock == nil || db.latestBlock.NextSigner() == next.Address() {
+		db.signer = next
+		return nil, nil
+	}
+
+	block, err := db.appendBlock(types.FullSignedBlock{
+		Ticker:      db.latestBlock.Ticker,
+		Timestamp:   db.latestBlock.Timestamp,
+		NextAddress: next.Address(),
+		Memo:        RotationMemo,
+	})
+	if err != nil {
+		return nil, err
+	}
+	db.signer = next
+
+	return &block, nil
+}
+
+// KeyHistory walks the chain and returns the keys that signed it, in order. The periods are not verified: use
+// Verify to check the signatures and the rotations
+func (db *BlockChain) KeyHistory() ([]KeyPeriod, error) {
+
+	var periods []KeyPeriod
+	err := db.Walk(func(block types.FullSignedBlock) error {
+		last := len(periods) - 1
+		if last >= 0 && periods[last].Address == block.Address {
+			periods[last].To = block.Height
+		} else {
+			periods = append(periods, KeyPeriod{Address: block.Address, From: block.Height, To: block.Height})
+		}
+		return nil
+	})
+
+	return periods, err
+}
@@ -0,0 +1,219 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database_test
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/identity"
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// Creates a new identity for a node
+func newSigner(t *testing.T) *identity.Identity {
+	t.Helper()
+
+	signer, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// Signs a block with the scheme of an older hash version, as the nodes did before the current one
+func signWithVersion(t *testing.T, block *types.FullSignedBlock, signer types.BlockSigner, version uint8) {
+	t.Helper()
+
+	block.Address = signer.Address()
+	block.HashVersion = version
+	hash, err := block.ComputeHash()
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Hash = hash
+
+	raw, err := hex.DecodeString(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	signature, err := signer.SignHash(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	block.Signature = hex.EncodeToString(signature)
+}
+
+// Encodes blocks as NDJSON, to be imported
+func ndjson(t *testing.T, blocks ...types.FullSignedBlock) *bytes.Buffer {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	for _, block := range blocks {
+		if err := encoder.Encode(block); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return &buffer
+}
+
+// A block hashed with CanonicalHashVersion doesn´t cover its NextAddress: anyone can add one to announce their key
+func TestNextAddressNotHashed(t *testing.T) {
+
+	owner := newSigner(t)
+	attacker := newSigner(t)
+
+	chain, err := database.NewBlockChain("BTCUSD", "", database.WithMemoryStore(), database.WithSigner(owner))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+	genesis, err := chain.NewFullSignedBlock("BTCUSD", 1583020800, 8750, 10, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A block of the owner, signed with the hash version 1, and the key of the attacker added after the signature
+	block := types.FullSignedBlock{
+		Height:          1,
+		Timestamp:       1583020805,
+		AveragePrice:    8751,
+		AverageVolume:   10,
+		Ticker:          "BTCUSD",
+		PreviousHash:    genesis.Hash,
+		PreviousAddress: owner.Address(),
+		MerkleRoot:      types.EvidenceRoot(nil),
+	}
+	signWithVersion(t, &block, owner, types.CanonicalHashVersion)
+	tampered := block
+	tampered.NextAddress = attacker.Address()
+	if err := tampered.VerifySignature(); err != nil {
+		t.Fatalf("the signature should still verify, as it doesn´t cover the NextAddress: %v", err)
+	}
+
+	if signer := tampered.NextSigner(); signer != owner.Address() {
+		t.Errorf("NextSigner() = %s, want the address of the block %s", signer, owner.Address())
+	}
+
+	// The import rejects the tampered block, and accepts the original one
+	for _, test := range []struct {
+		name  string
+		block types.FullSignedBlock
+		valid bool
+	}{
+		{name: "tampered", block: tampered},
+		{name: "original", block: block, valid: true},
+	} {
+		imported, err := database.NewBlockChain("BTCUSD", "", database.WithMemoryStore())
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = imported.ImportNDJSON(ndjson(t, genesis, test.block))
+		imported.Close()
+
+		if test.valid {
+			if err != nil {
+				t.Errorf("%s block: %v", test.name, err)
+			}
+			continue
+		}
+		var verificationErr *database.VerificationError
+		if !errors.As(err, &verificationErr) || verificationErr.Kind != database.BrokenSigner || verificationErr.Height != 1 {
+			t.Errorf("%s block imported with %v, want a broken signer at the height 1", test.name, err)
+		}
+	}
+
+	// A chain with the tampered block as the latest one can´t grow, neither with the key of the owner nor the
+	// key announced
+	store := database.NewMemoryStore()
+	for _, stored := range []types.FullSignedBlock{genesis, tampered} {
+		if err := store.CommitBlock(stored, database.LatestBlockKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, signer := range []*identity.Identity{owner, attacker} {
+		chain, err := database.NewBlockChain("BTCUSD", "", database.WithStore(store), database.WithSigner(signer))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := chain.NewFullSignedBlock("BTCUSD", 1583020810, 8752, 10, nil, ""); !errors.Is(err, database.ErrUnexpectedSigner) {
+			t.Errorf("block appended by %s after the tampered block with %v, want ErrUnexpectedSigner", signer.Address(), err)
+		}
+
+		report, err := chain.Verify()
+		var verificationErr *database.VerificationError
+		if !errors.As(err, &verificationErr) || verificationErr.Kind != database.BrokenSigner || report.Blocks != 1 {
+			t.Errorf("Verify returned %v after %d blocks, want a broken signer after 1", err, report.Blocks)
+		}
+	}
+}
+
+// A rotation interrupted after its block is written, e.g. by a crash before the keystore activates the next key,
+// leaves a chain that only the announced key can extend
+func TestInterruptedRotation(t *testing.T) {
+
+	current := newSigner(t)
+	next := newSigner(t)
+
+	store := database.NewMemoryStore()
+	chain, err := database.NewBlockChain("BTCUSD", "", database.WithStore(store), database.WithSigner(current))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := chain.NewFullSignedBlock("BTCUSD", 1583020800, 8750, 10, nil, ""); err != nil {
+		t.Fatal(err)
+	}
+	rotation, err := chain.RotateSigner(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rotation == nil || rotation.Height != 1 || rotation.NextAddress != next.Address() || rotation.Address != current.Address() {
+		t.Fatalf("RotateSigner() = %+v, want a block 1 of %s announcing %s", rotation, current.Address(), next.Address())
+	}
+
+	// The node restarts with the old key
+	restarted, err := database.NewBlockChain("BTCUSD", "", database.WithStore(store), database.WithSigner(current))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := restarted.NewFullSignedBlock("BTCUSD", 1583020805, 8751, 10, nil, ""); !errors.Is(err, database.ErrUnexpectedSigner) {
+		t.Fatalf("block appended with the old key with %v, want ErrUnexpectedSigner", err)
+	}
+
+	// Running the rotation again only replaces the signer
+	again, err := restarted.RotateSigner(next)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again != nil {
+		t.Errorf("RotateSigner() wrote the block %d, want no block", again.Height)
+	}
+	block, err := restarted.NewFullSignedBlock("BTCUSD", 1583020805, 8751, 10, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if block.Height != 2 || block.Address != next.Address() || block.PreviousAddress != current.Address() {
+		t.Errorf("block %d signed by %s after %s, want the block 2 signed by %s", block.Height, block.Address, block.PreviousAddress, next.Address())
+	}
+
+	periods, err := restarted.KeyHistory()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []database.KeyPeriod{
+		{Address: current.Address(), From: 0, To: 1},
+		{Address: next.Address(), From: 2, To: 2},
+	}
+	if len(periods) != len(expected) || periods[0] != expected[0] || periods[1] != expected[1] {
+		t.Errorf("KeyHistory() = %+v, want %+v", periods, expected)
+	}
+	if _, err := restarted.Verify(); err != nil {
+		t.Errorf("Verify() = %v", err)
+	}
+}
@@ -0,0 +1,389 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+	_ "github.com/mattn/go-sqlite3" // Driver for the SQLite store
+)
+
+// SQLiteFileName is the name of the database file of a SQLite store, inside the directory of the chain
+const SQLiteFileName = "chain.sqlite"
+
+// Schema of a SQLite store. The body of each block is kept as it was hashed, and the rest of the columns are
+// there to query the chain and the evidence with SQL
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS key_values (
+	key   TEXT PRIMARY KEY,
+	value BLOB NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS blocks (
+	hash             TEXT PRIMARY KEY,
+	ticker           TEXT NOT NULL,
+	height           INTEGER NOT NULL UNIQUE,
+	timestamp        INTEGER NOT NULL,
+	avg_price        REAL NOT NULL,
+	avg_volume       REAL NOT NULL,
+	previous_hash    TEXT NOT NULL,
+	address          TEXT NOT NULL,
+	previous_address TEXT NOT NULL,
+	memo             TEXT NOT NULL,
+	body             TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS blocks_ticker_height ON blocks (ticker, height);
+CREATE INDEX IF NOT EXISTS blocks_timestamp ON blocks (timestamp, height);
+
+CREATE TABLE IF NOT EXISTS evidence (
+	block_hash       TEXT NOT NULL REFERENCES blocks (hash),
+	block_height     INTEGER NOT NULL,
+	ticker           TEXT NOT NULL,
+	source_name      TEXT NOT NULL,
+	position         INTEGER NOT NULL,
+	hash             TEXT NOT NULL,
+	symbol           TEXT NOT NULL,
+	timestamp        INTEGER NOT NULL,
+	last_price       REAL NOT NULL,
+	volume           REAL NOT NULL,
+	quote_volume     REAL NOT NULL,
+	high_price       REAL NOT NULL,
+	open_price       REAL NOT NULL,
+	bid_price        REAL NOT NULL,
+	ask_price        REAL NOT NULL,
+	quote_currency   TEXT NOT NULL,
+	conversion_rate  REAL NOT NULL,
+	has_error        INTEGER NOT NULL,
+	error            TEXT NOT NULL,
+	excluded         INTEGER NOT NULL,
+	exclusion_reason TEXT NOT NULL,
+	data_url         TEXT NOT NULL,
+	PRIMARY KEY (block_hash, source_name)
+);
+CREATE INDEX IF NOT EXISTS evidence_ticker_height ON evidence (ticker, block_height);
+CREATE INDEX IF NOT EXISTS evidence_ticker_timestamp ON evidence (ticker, timestamp);
+CREATE INDEX IF NOT EXISTS evidence_source_timestamp ON evidence (source_name, timestamp);
+`
+
+// SQLiteStore implements the KVStore interface on a SQLite database, with a table for the blocks and another one
+// for their evidence
+type SQLiteStore struct {
+	FileLocation string
+
+	db *sql.DB
+}
+
+// NewSQLiteStore opens (or creates) the SQLite store of a chain in the directory
+func NewSQLiteStore(locationDirectory string) (*SQLiteStore, error) {
+
+	if err := os.MkdirAll(locationDirectory, 0700); err != nil {
+		return nil, err
+	}
+
+	location := filepath.Join(locationDirectory, SQLiteFileName)
+	db, err := sql.Open("sqlite3", "file:"+location+"?_journal_mode=WAL&_busy_timeout=5000&_foreign_keys=on")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite allows only one writer, so all the queries share the connection
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{
+		FileLocation: location,
+		db:           db,
+	}, nil
+}
+
+// OpenSQLiteStoreReadOnly opens the SQLite store of an existing chain without writing to it
+func OpenSQLiteStoreReadOnly(locationDirectory string) (*SQLiteStore, error) {
+
+	location := filepath.Join(locationDirectory, SQLiteFileName)
+	if _, err := os.Stat(location); err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+location+"?mode=ro&_busy_timeout=5000")
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &SQLiteStore{
+		FileLocation: location,
+		db:           db,
+	}, nil
+}
+
+// Close releases the database. The store can´t be used after it
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// SQLite integers are signed, so the greater values are limited to the maximum one
+func sqliteInt(value uint64) int64 {
+	if value > math.MaxInt64 {
+		return math.MaxInt64
+	}
+	return int64(value)
+}
+
+// Returns 1 for true, as the booleans are stored in SQLite
+func sqliteBool(value bool) int {
+	if value {
+		return 1
+	}
+	return 0
+}
+
+// StoreValue stores an abritrary value in the database, indexed by a string
+func (s *SQLiteStore) StoreValue(key string, value []byte) error {
+	_, err := s.db.Exec(`INSERT OR REPLACE INTO key_values (key, value) VALUES (?, ?)`, key, value)
+	return err
+}
+
+// GetValue returns a value stored in the database indexed by an string
+func (s *SQLiteStore) GetValue(key string) ([]byte, error) {
+
+	var value []byte
+	err := s.db.QueryRow(`SELECT value FROM key_values WHERE key = ?`, key).Scan(&value)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+
+	return value, err
+}
+
+// Write a block (serialized in body) with its evidence. There can´t be two blocks with the same height
+func insertBlock(tx *sql.Tx, block types.FullSignedBlock, body []byte) error {
+
+	var exists int
+	err := tx.QueryRow(`SELECT COUNT(*) FROM blocks WHERE height = ?`, sqliteInt(block.Height)).Scan(&exists)
+	if err != nil {
+		return err
+	}
+	if exists > 0 {
+		return fmt.Errorf("there is already a block with height %d", block.Height)
+	}
+
+	_, err = tx.Exec(`INSERT INTO blocks (hash, ticker, height, timestamp, avg_price, avg_volume, previous_hash,
+		address, previous_address, memo, body) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		block.Hash, block.Ticker, sqliteInt(block.Height), sqliteInt(block.Timestamp), block.AveragePrice,
+		block.AverageVolume, block.PreviousHash, block.Address, block.PreviousAddress, block.Memo, string(body))
+	if err != nil {
+		return err
+	}
+
+	for position, source := range block.Evidence {
+		_, err = tx.Exec(`INSERT INTO evidence (block_hash, block_height, ticker, source_name, position, hash,
+			symbol, timestamp, last_price, volume, quote_volume, high_price, open_price, bid_price, ask_price,
+			quote_currency, conversion_rate, has_error, error, excluded, exclusion_reason, data_url)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			block.Hash, sqliteInt(block.Height), block.Ticker, source.CrawlerName, position, source.Hash,
+			source.Ticker, source.Timestamp,
+			source.Data.LastPrice, source.Data.Volume, source.Data.QuoteVolume, source.Data.HighPrice,
+			source.Data.OpenPrice, source.Data.BidPrice, source.Data.AskPrice, source.Data.QuoteCurrency,
+			source.ConversionRate, sqliteBool(source.HasError), source.Error, sqliteBool(source.Excluded),
+			source.ExclusionReason, source.Data.DataURL)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Write a block, and the block as the value of latestKey if it is not empty, in a transaction
+func (s *SQLiteStore) commit(block types.FullSignedBlock, latestKey string) error {
+
+	body, err := json.Marshal(block)
+	if err != nil {
+		return err
+	}
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	if err := insertBlock(tx, block, body); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if latestKey != "" {
+		if _, err := tx.Exec(`INSERT OR REPLACE INTO key_values (key, value) VALUES (?, ?)`, latestKey, body); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// StoreBlock stores a block with its evidence
+func (s *SQLiteStore) StoreBlock(block types.FullSignedBlock) error {
+	return s.commit(block, "")
+}
+
+// CommitBlock stores a block with its evidence, and the block as the value of latestKey, in a single transaction.
+// If something fails, nothing is written
+func (s *SQLiteStore) CommitBlock(block types.FullSignedBlock, latestKey string) error {
+	return s.commit(block, latestKey)
+}
+
+// Read the blocks returned by a query of their bodies
+func (s *SQLiteStore) queryBlocks(query string, args ...interface{}) ([]types.FullSignedBlock, error) {
+
+	rows, err := s.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var blocks []types.FullSignedBlock
+	for rows.Next() {
+		var body []byte
+		if err := rows.Scan(&body); err != nil {
+			return nil, err
+		}
+
+		var block types.FullSignedBlock
+		if err := json.Unmarshal(body, &block); err != nil {
+			return nil, err
+		}
+		blocks = append(blocks, block)
+	}
+
+	return blocks, rows.Err()
+}
+
+// Read the first block returned by a query, or ErrNotFound
+func (s *SQLiteStore) queryBlock(query string, args ...interface{}) (*types.FullSignedBlock, error) {
+
+	blocks, err := s.queryBlocks(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	if len(blocks) == 0 {
+		return nil, ErrNotFound
+	}
+
+	return &blocks[0], nil
+}
+
+// GetBlock reads a block from the database using their hash
+func (s *SQLiteStore) GetBlock(hash string) (*types.FullSignedBlock, error) {
+	return s.queryBlock(`SELECT body FROM blocks WHERE hash = ?`, hash)
+}
+
+// FindBlockByTimestamp reads a block using their timestamp. If several blocks have the same timestamp, the lowest
+// one is returned
+func (s *SQLiteStore) FindBlockByTimestamp(timestamp uint64) (*types.FullSignedBlock, error) {
+	return s.queryBlock(`SELECT body FROM blocks WHERE timestamp = ? ORDER BY height LIMIT 1`, sqliteInt(timestamp))
+}
+
+// FindBlockByHeight reads a block using their height
+func (s *SQLiteStore) FindBlockByHeight(height uint64) (*types.FullSignedBlock, error) {
+	return s.queryBlock(`SELECT body FROM blocks WHERE height = ?`, sqliteInt(height))
+}
+
+// Returns the order and the limit of a range query. SQLite takes a negative limit as no limit
+func rangeClauses(reverse bool, limit int) (string, int) {
+	order := "ASC"
+	if reverse {
+		order = "DESC"
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+	return order, limit
+}
+
+// BlocksByHeight returns the blocks with a height between from and to (both included), ordered by height, or
+// in reverse order. A limit of 0 means no limit
+func (s *SQLiteStore) BlocksByHeight(from uint64, to uint64, limit int, reverse bool) ([]types.FullSignedBlock, error) {
+	order, limit := rangeClauses(reverse, limit)
+	return s.queryBlocks(`SELECT body FROM blocks WHERE height BETWEEN ? AND ? ORDER BY height `+order+` LIMIT ?`,
+		sqliteInt(from), sqliteInt(to), limit)
+}
+
+// BlocksByTimestamp returns the blocks with a timestamp between from and to (both included), ordered by time, or
+// in reverse order. A limit of 0 means no limit
+func (s *SQLiteStore) BlocksByTimestamp(from uint64, to uint64, limit int, reverse bool) ([]types.FullSignedBlock, error) {
+	order, limit := rangeClauses(reverse, limit)
+	return s.queryBlocks(`SELECT body FROM blocks WHERE timestamp BETWEEN ? AND ? ORDER BY timestamp `+order+`, height `+order+` LIMIT ?`,
+		sqliteInt(from), sqliteInt(to), limit)
+}
+
+// EvidenceRecord is a row of the evidence table: a source of a block, with the market and the height of the block
+type EvidenceRecord struct {
+	BlockHash   string
+	BlockHeight uint64
+	Ticker      string // Market of the block, like BTCUSD
+	Position    int    // Position of the source in the evidence of the block
+	Source      string // Name of the crawler
+	Symbol      string // Code of the market in the venue
+	Hash        string
+	Timestamp   int64
+
+	LastPrice      float64
+	Volume         float64
+	QuoteVolume    float64
+	HighPrice      float64
+	OpenPrice      float64
+	BidPrice       float64
+	AskPrice       float64
+	QuoteCurrency  string
+	ConversionRate float64
+
+	HasError        bool
+	Error           string
+	Excluded        bool
+	ExclusionReason string
+	DataURL         string
+}
+
+// EvidenceAt returns the evidence of the block of a market (by ticker) at a height, in the order of the block
+func (s *SQLiteStore) EvidenceAt(ticker string, height uint64) ([]EvidenceRecord, error) {
+
+	rows, err := s.db.Query(`SELECT block_hash, block_height, ticker, position, source_name, symbol, hash, timestamp,
+		last_price, volume, quote_volume, high_price, open_price, bid_price, ask_price, quote_currency,
+		conversion_rate, has_error, error, excluded, exclusion_reason, data_url
+		FROM evidence WHERE ticker = ? AND block_height = ? ORDER BY position`, ticker, sqliteInt(height))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var records []EvidenceRecord
+	for rows.Next() {
+		var record EvidenceRecord
+		var blockHeight int64
+		err := rows.Scan(&record.BlockHash, &blockHeight, &record.Ticker, &record.Position, &record.Source,
+			&record.Symbol, &record.Hash, &record.Timestamp, &record.LastPrice, &record.Volume, &record.QuoteVolume,
+			&record.HighPrice, &record.OpenPrice, &record.BidPrice, &record.AskPrice, &record.QuoteCurrency,
+			&record.ConversionRate, &record.HasError, &record.Error, &record.Excluded, &record.ExclusionReason,
+			&record.DataURL)
+		if err != nil {
+			return nil, err
+		}
+		record.BlockHeight = uint64(blockHeight)
+		records = append(records, record)
+	}
+
+	return records, rows.Err()
+}
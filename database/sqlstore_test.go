@@ -0,0 +1,30 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database_test
+
+import (
+	"testing"
+
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/database/storetest"
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+func TestSQLiteStore(t *testing.T) {
+	newStore := func() (types.KVStore, error) {
+		return database.NewSQLiteStore(t.TempDir())
+	}
+	if err := storetest.TestKVStore(newStore); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSQLiteStoreEvidence(t *testing.T) {
+	newStore := func() (*database.SQLiteStore, error) {
+		return database.NewSQLiteStore(t.TempDir())
+	}
+	if err := storetest.TestSQLiteEvidence(newStore); err != nil {
+		t.Fatal(err)
+	}
+}
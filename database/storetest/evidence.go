@@ -0,0 +1,143 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package storetest
+
+import (
+	"fmt"
+
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// Returns a block with a healthy source, a converted one and a failed one excluded
+func evidenceBlock(height uint64, previousHash string) types.FullSignedBlock {
+
+	timestamp := int64(1573257600 + 60*height)
+	block := types.FullSignedBlock{
+		Height:        height,
+		Timestamp:     uint64(timestamp),
+		AveragePrice:  8750,
+		AverageVolume: 150,
+		Ticker:        "BTCUSD",
+		PreviousHash:  previousHash,
+		Evidence: []types.Result{
+			{CrawlerName: "Kraken", Ticker: "XBTUSD", Timestamp: timestamp, Data: types.QuotePriceInfo{
+				LastPrice: 8751.4, Volume: 100, QuoteVolume: 875140, HighPrice: 8867.8, OpenPrice: 8805.9,
+				QuoteCurrency: "USD", DataURL: "https://api.kraken.com/0/public/Ticker?pair=XBTUSD",
+			}},
+			{CrawlerName: "Binance", Ticker: "BTCUSDT", Timestamp: timestamp, ConversionRate: 0.999, Data: types.QuotePriceInfo{
+				LastPrice: 8760, Volume: 50, BidPrice: 8759.5, AskPrice: 8760.5, QuoteCurrency: "USDT",
+			}},
+			{CrawlerName: "UpBit", Ticker: "KRW-BTC", Timestamp: timestamp, HasError: true, Error: "timeout",
+				Excluded: true, ExclusionReason: types.ExclusionQuoteMismatch},
+		},
+	}
+	for i := range block.Evidence {
+		block.Evidence[i].CreateHash()
+	}
+	block.MerkleRoot = types.EvidenceRoot(block.Evidence)
+	block.CreateHash()
+
+	return block
+}
+
+// Compare the rows of the evidence of a block with its sources
+func expectEvidence(records []database.EvidenceRecord, block types.FullSignedBlock) error {
+
+	if len(records) != len(block.Evidence) {
+		return fmt.Errorf("%d rows of evidence for the block %d, expected %d", len(records), block.Height, len(block.Evidence))
+	}
+	for position, source := range block.Evidence {
+		record := records[position]
+		expected := database.EvidenceRecord{
+			BlockHash:       block.Hash,
+			BlockHeight:     block.Height,
+			Ticker:          block.Ticker,
+			Position:        position,
+			Source:          source.CrawlerName,
+			Symbol:          source.Ticker,
+			Hash:            source.Hash,
+			Timestamp:       source.Timestamp,
+			LastPrice:       source.Data.LastPrice,
+			Volume:          source.Data.Volume,
+			QuoteVolume:     source.Data.QuoteVolume,
+			HighPrice:       source.Data.HighPrice,
+			OpenPrice:       source.Data.OpenPrice,
+			BidPrice:        source.Data.BidPrice,
+			AskPrice:        source.Data.AskPrice,
+			QuoteCurrency:   source.Data.QuoteCurrency,
+			ConversionRate:  source.ConversionRate,
+			HasError:        source.HasError,
+			Error:           source.Error,
+			Excluded:        source.Excluded,
+			ExclusionReason: source.ExclusionReason,
+			DataURL:         source.Data.DataURL,
+		}
+		if record != expected {
+			return fmt.Errorf("row %d of the block %d is %+v, expected %+v", position, block.Height, record, expected)
+		}
+	}
+
+	return nil
+}
+
+// TestSQLiteEvidence checks that the SQLite stores created by newStore write the sources of the blocks in their
+// evidence table, with the market and the height of the block, both with StoreBlock and CommitBlock
+func TestSQLiteEvidence(newStore func() (*database.SQLiteStore, error)) error {
+
+	store, err := newStore()
+	if err != nil {
+		return fmt.Errorf("unable to create a store: %v", err)
+	}
+	defer store.Close()
+
+	first := evidenceBlock(0, "")
+	second := evidenceBlock(1, first.Hash)
+	if err := store.StoreBlock(first); err != nil {
+		return fmt.Errorf("StoreBlock(0): %v", err)
+	}
+	if err := store.CommitBlock(second, latestKey); err != nil {
+		return fmt.Errorf("CommitBlock(1): %v", err)
+	}
+
+	for _, block := range []types.FullSignedBlock{first, second} {
+		records, err := store.EvidenceAt(block.Ticker, block.Height)
+		if err != nil {
+			return fmt.Errorf("EvidenceAt(%d): %v", block.Height, err)
+		}
+		if err := expectEvidence(records, block); err != nil {
+			return err
+		}
+	}
+
+	// A failed commit doesn´t leave its evidence
+	duplicated := evidenceBlock(1, first.Hash)
+	duplicated.Evidence = duplicated.Evidence[:1]
+	duplicated.CreateHash()
+	if err := store.CommitBlock(duplicated, latestKey); err == nil {
+		return fmt.Errorf("CommitBlock accepted a second block with the height 1")
+	}
+	records, err := store.EvidenceAt(second.Ticker, second.Height)
+	if err != nil {
+		return err
+	}
+	if err := expectEvidence(records, second); err != nil {
+		return fmt.Errorf("after a failed commit: %v", err)
+	}
+
+	for _, missing := range []struct {
+		ticker string
+		height uint64
+	}{{"BTCUSD", 2}, {"ETHUSD", 0}} {
+		records, err := store.EvidenceAt(missing.ticker, missing.height)
+		if err != nil {
+			return err
+		}
+		if len(records) != 0 {
+			return fmt.Errorf("EvidenceAt(%s, %d) returned %d rows, expected none", missing.ticker, missing.height, len(records))
+		}
+	}
+
+	return nil
+}
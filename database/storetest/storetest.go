@@ -0,0 +1,338 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+
+// Package storetest checks that an implementation of types.KVStore has the semantics expected by the blockchain.
+// Every store must pass it, calling TestKVStore from their tests:
+//
+//	if err := storetest.TestKVStore(func() (types.KVStore, error) { return database.NewMemoryStore(), nil }); err != nil {
+//		t.Fatal(err)
+//	}
+//
+// The SQLite stores must pass TestSQLiteEvidence too, for the rows of their evidence table
+package storetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// Number of blocks of the test chain. More than 256, so the order of the indexes is checked beyond the first byte
+const chainLength = 300
+
+// Key used for the latest block in the checks of CommitBlock
+const latestKey = "latest"
+
+// Each check receives an empty store
+type check struct {
+	name string
+	run  func(store types.KVStore) error
+}
+
+var checks = []check{
+	{"values", checkValues},
+	{"blocks", checkBlocks},
+	{"height ranges", checkHeightRanges},
+	{"timestamp ranges", checkTimestampRanges},
+	{"commit", checkCommit},
+}
+
+// TestKVStore runs all the checks over the stores created by newStore. Each check uses a new empty store, which is
+// closed at the end. It returns an error describing all the failed checks
+func TestKVStore(newStore func() (types.KVStore, error)) error {
+
+	var failures []string
+	for _, c := range checks {
+		store, err := newStore()
+		if err != nil {
+			return fmt.Errorf("unable to create a store: %v", err)
+		}
+
+		if err := c.run(store); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %v", c.name, err))
+		}
+		if err := store.Close(); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: closing the store: %v", c.name, err))
+		}
+	}
+
+	if len(failures) > 0 {
+		return fmt.Errorf("the store failed %d checks:\n%s", len(failures), strings.Join(failures, "\n"))
+	}
+
+	return nil
+}
+
+// Returns the test chain. Every fifth block has the same timestamp as the previous one
+func testChain() []types.FullSignedBlock {
+
+	var chain []types.FullSignedBlock
+	var timestamp uint64 = 1573257600
+
+	for height := uint64(0); height < chainLength; height++ {
+		if height%5 != 0 {
+			timestamp += 60
+		}
+		block := types.FullSignedBlock{
+			Height:        height,
+			Timestamp:     timestamp,
+			AveragePrice:  8750 + float64(height)/8,
+			AverageVolume: 100,
+			Ticker:        "BTCUSD",
+			Evidence: []types.Result{
+				{CrawlerName: "Test", Timestamp: int64(timestamp), Data: types.QuotePriceInfo{LastPrice: 8750}},
+			},
+		}
+		if height > 0 {
+			block.PreviousHash = chain[height-1].Hash
+		}
+		block.CreateHash()
+		chain = append(chain, block)
+	}
+
+	return chain
+}
+
+// Store all the blocks of a chain
+func storeChain(store types.KVStore, chain []types.FullSignedBlock) error {
+	for _, block := range chain {
+		if err := store.StoreBlock(block); err != nil {
+			return fmt.Errorf("StoreBlock(%d): %v", block.Height, err)
+		}
+	}
+	return nil
+}
+
+// Compare two blocks by their serialization
+func sameBlock(a types.FullSignedBlock, b types.FullSignedBlock) bool {
+	x, _ := json.Marshal(a)
+	y, _ := json.Marshal(b)
+	return bytes.Equal(x, y)
+}
+
+// Compare the heights of a list of blocks with the expected ones
+func expectHeights(name string, blocks []types.FullSignedBlock, expected ...uint64) error {
+	var found []uint64
+	for _, block := range blocks {
+		found = append(found, block.Height)
+	}
+	if fmt.Sprint(found) != fmt.Sprint(expected) {
+		return fmt.Errorf("%s returned the heights %v, expected %v", name, found, expected)
+	}
+	return nil
+}
+
+// Heights from first to last, both included, going down if first > last
+func heights(first uint64, last uint64) []uint64 {
+	var list []uint64
+	for h := first; ; {
+		list = append(list, h)
+		if h == last {
+			return list
+		}
+		if first < last {
+			h++
+		} else {
+			h--
+		}
+	}
+}
+
+func checkValues(store types.KVStore) error {
+
+	if _, err := store.GetValue("missing"); err == nil {
+		return fmt.Errorf("GetValue of a missing key didn´t return an error")
+	}
+	if err := store.StoreValue("key", []byte("first")); err != nil {
+		return err
+	}
+	if err := store.StoreValue("key", []byte("second")); err != nil {
+		return err
+	}
+	value, err := store.GetValue("key")
+	if err != nil {
+		return err
+	}
+	if string(value) != "second" {
+		return fmt.Errorf("GetValue returned %q, expected %q", value, "second")
+	}
+
+	return nil
+}
+
+func checkBlocks(store types.KVStore) error {
+
+	chain := testChain()
+	if err := storeChain(store, chain); err != nil {
+		return err
+	}
+
+	for _, expected := range []types.FullSignedBlock{chain[0], chain[255], chain[256], chain[chainLength-1]} {
+		block, err := store.GetBlock(expected.Hash)
+		if err != nil {
+			return fmt.Errorf("GetBlock(%d): %v", expected.Height, err)
+		}
+		if !sameBlock(*block, expected) {
+			return fmt.Errorf("GetBlock(%d) returned a different block", expected.Height)
+		}
+
+		block, err = store.FindBlockByHeight(expected.Height)
+		if err != nil {
+			return fmt.Errorf("FindBlockByHeight(%d): %v", expected.Height, err)
+		}
+		if !sameBlock(*block, expected) {
+			return fmt.Errorf("FindBlockByHeight(%d) returned the block %d", expected.Height, block.Height)
+		}
+	}
+
+	// Blocks 4 and 5 share their timestamp: the lowest one is returned
+	block, err := store.FindBlockByTimestamp(chain[5].Timestamp)
+	if err != nil {
+		return fmt.Errorf("FindBlockByTimestamp: %v", err)
+	}
+	if block.Height != 4 {
+		return fmt.Errorf("FindBlockByTimestamp returned the block %d, expected 4", block.Height)
+	}
+
+	if _, err := store.GetBlock("missing"); err == nil {
+		return fmt.Errorf("GetBlock of a missing hash didn´t return an error")
+	}
+	if _, err := store.FindBlockByHeight(chainLength); err == nil {
+		return fmt.Errorf("FindBlockByHeight of a missing height didn´t return an error")
+	}
+	if _, err := store.FindBlockByTimestamp(chain[1].Timestamp + 1); err == nil {
+		return fmt.Errorf("FindBlockByTimestamp of a missing timestamp didn´t return an error")
+	}
+	if err := store.StoreBlock(chain[10]); err == nil {
+		return fmt.Errorf("StoreBlock accepted a second block with the height 10")
+	}
+
+	return nil
+}
+
+func checkHeightRanges(store types.KVStore) error {
+
+	if err := storeChain(store, testChain()); err != nil {
+		return err
+	}
+
+	blocks, err := store.BlocksByHeight(250, 260, 0, false)
+	if err != nil {
+		return err
+	}
+	if err := expectHeights("BlocksByHeight(250, 260)", blocks, heights(250, 260)...); err != nil {
+		return err
+	}
+
+	blocks, err = store.BlocksByHeight(250, 260, 3, true)
+	if err != nil {
+		return err
+	}
+	if err := expectHeights("BlocksByHeight(250, 260, reverse, limit 3)", blocks, 260, 259, 258); err != nil {
+		return err
+	}
+
+	blocks, err = store.BlocksByHeight(chainLength-2, chainLength+10, 0, false)
+	if err != nil {
+		return err
+	}
+	if err := expectHeights("BlocksByHeight beyond the last block", blocks, chainLength-2, chainLength-1); err != nil {
+		return err
+	}
+
+	blocks, err = store.BlocksByHeight(20, 10, 0, false)
+	if err != nil {
+		return err
+	}
+	if len(blocks) != 0 {
+		return fmt.Errorf("BlocksByHeight(20, 10) returned %d blocks, expected none", len(blocks))
+	}
+
+	return nil
+}
+
+func checkTimestampRanges(store types.KVStore) error {
+
+	chain := testChain()
+	if err := storeChain(store, chain); err != nil {
+		return err
+	}
+
+	// The blocks with the same timestamp are returned in order of height
+	blocks, err := store.BlocksByTimestamp(chain[3].Timestamp, chain[6].Timestamp, 0, false)
+	if err != nil {
+		return err
+	}
+	if err := expectHeights("BlocksByTimestamp", blocks, 3, 4, 5, 6); err != nil {
+		return err
+	}
+
+	blocks, err = store.BlocksByTimestamp(chain[3].Timestamp, chain[6].Timestamp, 0, true)
+	if err != nil {
+		return err
+	}
+	if err := expectHeights("BlocksByTimestamp in reverse", blocks, 6, 5, 4, 3); err != nil {
+		return err
+	}
+
+	// The last blocks before a time
+	blocks, err = store.BlocksByTimestamp(0, chain[262].Timestamp-1, 3, true)
+	if err != nil {
+		return err
+	}
+	if err := expectHeights("BlocksByTimestamp before a time", blocks, 261, 260, 259); err != nil {
+		return err
+	}
+
+	blocks, err = store.BlocksByTimestamp(0, chain[0].Timestamp-1, 0, false)
+	if err != nil {
+		return err
+	}
+	if len(blocks) != 0 {
+		return fmt.Errorf("BlocksByTimestamp before the first block returned %d blocks, expected none", len(blocks))
+	}
+
+	return nil
+}
+
+func checkCommit(store types.KVStore) error {
+
+	chain := testChain()
+	for _, block := range chain[:3] {
+		if err := store.CommitBlock(block, latestKey); err != nil {
+			return fmt.Errorf("CommitBlock(%d): %v", block.Height, err)
+		}
+	}
+
+	latest, err := store.GetValue(latestKey)
+	if err != nil {
+		return err
+	}
+	var block types.FullSignedBlock
+	if err := json.Unmarshal(latest, &block); err != nil {
+		return err
+	}
+	if !sameBlock(block, chain[2]) {
+		return fmt.Errorf("the latest block is %d, expected 2", block.Height)
+	}
+
+	// A failed commit doesn´t write anything
+	duplicated := chain[3]
+	duplicated.Height = 1
+	duplicated.CreateHash()
+	if err := store.CommitBlock(duplicated, latestKey); err == nil {
+		return fmt.Errorf("CommitBlock accepted a second block with the height 1")
+	}
+	if _, err := store.GetBlock(duplicated.Hash); err == nil {
+		return fmt.Errorf("the block of a failed commit was stored")
+	}
+	if latest2, err := store.GetValue(latestKey); err != nil || !bytes.Equal(latest, latest2) {
+		return fmt.Errorf("a failed commit changed the latest block")
+	}
+
+	return nil
+}
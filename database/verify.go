@@ -0,0 +1,186 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// Kinds of broken links found by Verify
+const (
+	BrokenBlockHash       = "block-hash"       // The block hash doesn´t match the content of the block
+	BrokenEvidenceHash    = "evidence-hash"    // The hash of a source doesn´t match its content
+	BrokenMerkleRoot      = "merkle-root"      // The Merkle root doesn´t match the hashes of the evidence
+	BrokenHeight          = "height"           // A height is missing, or the block doesn´t have the expected height
+	BrokenPreviousHash    = "previous-hash"    // The block is not chained to the hash of the previous one
+	BrokenPreviousAddress = "previous-address" // The block is not chained to the address of the previous one
+	BrokenSignature       = "signature"        // The block is not signed by the owner of its address
+	BrokenSigner          = "signer"           // The block is not signed by the key announced by the previous one
+	BrokenLatest          = "latest"           // The latest pointer doesn´t point to the last block of the chain
+)
+
+// VerificationError describes the first broken link found in a chain
+type VerificationError struct {
+	Kind     string // One of the Broken* values
+	Height   uint64 // Height where the chain is broken
+	Hash     string // Hash of the block, as stored
+	Evidence int    // Position of the source in the evidence of the block, or -1 if the error is in the block
+	Expected string
+	Found    string
+}
+
+func (e *VerificationError) Error() string {
+	where := fmt.Sprintf("block %d (%s)", e.Height, e.Hash)
+	if e.Evidence >= 0 {
+		where = fmt.Sprintf("source %d of %s", e.Evidence, where)
+	}
+
+	return fmt.Sprintf("chain broken at %s: %s, expected %q but found %q", where, e.Kind, e.Expected, e.Found)
+}
+
+// VerificationReport is the summary of a chain verification
+type VerificationReport struct {
+	Blocks     uint64 `json:"blocks"`     // Number of verified blocks
+	Sources    int    `json:"sources"`    // Number of verified sources, from all the blocks
+	LatestHash string `json:"latestHash"` // Hash of the last verified block
+}
+
+// Verify walks the chain from the genesis block to the latest one, recomputing the hashes of the blocks and their
+// sources, and checking the links between each block and the previous one. It returns a *VerificationError with
+// the first broken link, and the report of the blocks verified until then
+func (db *BlockChain) Verify() (VerificationReport, error) {
+
+	var report VerificationReport
+	var previous *types.FullSignedBlock
+
+	err := db.Walk(func(block types.FullSignedBlock) error {
+		if err := verifyBlock(block, previous); err != nil {
+			return err
+		}
+
+		report.Blocks++
+		report.Sources += len(block.Evidence)
+		report.LatestHash = block.Hash
+		previous = &block
+
+		return nil
+	})
+	if err != nil {
+		return report, err
+	}
+
+	return report, db.verifyLatest(previous)
+}
+
+// Check a block, and its links with the previous one (nil for the genesis block)
+func verifyBlock(block types.FullSignedBlock, previous *types.FullSignedBlock) error {
+
+	brokenLink := func(kind string, expected string, found string) error {
+		return &VerificationError{
+			Kind:     kind,
+			Height:   block.Height,
+			Hash:     block.Hash,
+			Evidence: -1,
+			Expected: expected,
+			Found:    found,
+		}
+	}
+
+	var expectedHeight uint64
+	var expectedHash, expectedAddress, expectedSigner string
+	if previous != nil {
+		expectedHeight = previous.Height + 1
+		expectedHash = previous.Hash
+		expectedAddress = previous.Address
+		expectedSigner = previous.NextSigner()
+	}
+
+	if block.Height != expectedHeight {
+		return brokenLink(BrokenHeight, fmt.Sprint(expectedHeight), fmt.Sprint(block.Height))
+	}
+	if block.PreviousHash != expectedHash {
+		return brokenLink(BrokenPreviousHash, expectedHash, block.PreviousHash)
+	}
+	if block.PreviousAddress != expectedAddress {
+		return brokenLink(BrokenPreviousAddress, expectedAddress, block.PreviousAddress)
+	}
+	// Once the chain is signed, the key only changes with a rotation block, signed by the old key. The first signed
+	// block of a chain created before the node identities can have any key
+	if expectedSigner != "" && block.Address != expectedSigner {
+		return brokenLink(BrokenSigner, expectedSigner, block.Address)
+	}
+	if block.NextAddress != "" && block.Address == "" {
+		return brokenLink(BrokenSigner, "a signed block to announce "+block.NextAddress, block.Address)
+	}
+	// The hash only covers the NextAddress since KeyRotationHashVersion. In an older block it could be forged
+	if block.NextAddress != "" && block.HashVersion < types.KeyRotationHashVersion {
+		return brokenLink(BrokenSigner, fmt.Sprintf("no next key in a block of the hash version %d", block.HashVersion), block.NextAddress)
+	}
+
+	// The hashes are recomputed with the scheme used when they were created
+	for i, source := range block.Evidence {
+		expected, err := source.ComputeHash()
+		if err != nil {
+			return err
+		}
+		if expected != source.Hash {
+			return &VerificationError{
+				Kind:     BrokenEvidenceHash,
+				Height:   block.Height,
+				Hash:     block.Hash,
+				Evidence: i,
+				Expected: expected,
+				Found:    source.Hash,
+			}
+		}
+	}
+
+	// The canonical hash covers the evidence through the Merkle root. The legacy blocks created before the Merkle
+	// roots don´t have one
+	root := types.EvidenceRoot(block.Evidence)
+	if (block.HashVersion >= types.CanonicalHashVersion || block.MerkleRoot != "") && block.MerkleRoot != root {
+		return brokenLink(BrokenMerkleRoot, root, block.MerkleRoot)
+	}
+
+	expected, err := block.ComputeHash()
+	if err != nil {
+		return err
+	}
+	if expected != block.Hash {
+		return brokenLink(BrokenBlockHash, expected, block.Hash)
+	}
+
+	// The blocks created without the identity of a node don´t have address nor signature
+	if block.Address != "" || block.Signature != "" {
+		if err := block.VerifySignature(); err != nil {
+			return brokenLink(BrokenSignature, "a signature by "+block.Address, block.Signature)
+		}
+	}
+
+	return nil
+}
+
+// Check that the latest pointer is the last block of the chain
+func (db *BlockChain) verifyLatest(last *types.FullSignedBlock) error {
+
+	var latest types.FullSignedBlock
+	bytes, err := db.kvstore.GetValue(LatestBlockKey)
+	if err == nil {
+		err = json.Unmarshal(bytes, &latest)
+	}
+
+	switch {
+	case last == nil && err != nil: // Empty chain
+		return nil
+	case last == nil:
+		return &VerificationError{Kind: BrokenLatest, Height: latest.Height, Hash: latest.Hash, Evidence: -1, Found: latest.Hash}
+	case err != nil || latest.Hash != last.Hash:
+		return &VerificationError{Kind: BrokenLatest, Height: last.Height, Hash: last.Hash, Evidence: -1, Expected: last.Hash, Found: latest.Hash}
+	}
+
+	return nil
+}
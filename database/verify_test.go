@@ -0,0 +1,196 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package database_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/identity"
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// Creates the hashed quotes of the sources of a block
+func newEvidence(t *testing.T, timestamp int64, price float64, sources ...string) []types.Result {
+	t.Helper()
+
+	evidence := make([]types.Result, len(sources))
+	for i, source := range sources {
+		evidence[i] = types.Result{
+			CrawlerName: source,
+			Ticker:      "BTCUSD",
+			Timestamp:   timestamp,
+			Data:        types.QuotePriceInfo{LastPrice: price + float64(i), Volume: 1, Timestamp: timestamp},
+		}
+		if err := evidence[i].CreateHash(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return evidence
+}
+
+// Creates the blocks of a signed chain, with three sources in each block
+func newSignedBlocks(t *testing.T, signer *identity.Identity, count int) []types.FullSignedBlock {
+	t.Helper()
+
+	chain, err := database.NewBlockChain("BTCUSD", "", database.WithMemoryStore(), database.WithSigner(signer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer chain.Close()
+
+	blocks := make([]types.FullSignedBlock, count)
+	for i := range blocks {
+		timestamp := 1583020800 + int64(i)*5
+		evidence := newEvidence(t, timestamp, 8750+float64(i), "binance", "coinbase", "kraken")
+		blocks[i], err = chain.NewFullSignedBlock("BTCUSD", uint64(timestamp), 8751+float64(i), 10, evidence, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	return blocks
+}
+
+// Opens a chain with the blocks written as they are, without checking them
+func chainOf(t *testing.T, blocks []types.FullSignedBlock) (*database.BlockChain, *database.MemoryStore) {
+	t.Helper()
+
+	store := database.NewMemoryStore()
+	for _, block := range blocks {
+		if err := store.CommitBlock(block, database.LatestBlockKey); err != nil {
+			t.Fatal(err)
+		}
+	}
+	chain, err := database.NewBlockChain("BTCUSD", "", database.WithStore(store))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chain.Close() })
+
+	return chain, store
+}
+
+func TestVerify(t *testing.T) {
+
+	signer := newSigner(t)
+	blocks := newSignedBlocks(t, signer, 4)
+
+	chain, _ := chainOf(t, blocks)
+	report, err := chain.Verify()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Blocks != 4 || report.Sources != 12 || report.LatestHash != blocks[3].Hash {
+		t.Errorf("Verify() = %+v, expected 4 blocks and 12 sources up to %s", report, blocks[3].Hash)
+	}
+
+	empty, _ := chainOf(t, nil)
+	if report, err := empty.Verify(); err != nil || report.Blocks != 0 {
+		t.Errorf("Verify() of an empty chain = %+v, %v", report, err)
+	}
+}
+
+func TestVerifyBroken(t *testing.T) {
+
+	signer := newSigner(t)
+	blocks := newSignedBlocks(t, signer, 4)
+
+	// Signs a block again after a change, so only the change is broken
+	resign := func(t *testing.T, block *types.FullSignedBlock) {
+		if err := block.Sign(signer); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		tamper   func(t *testing.T, block *types.FullSignedBlock)
+		kind     string
+		evidence int
+	}{
+		{name: "block hash", kind: database.BrokenBlockHash, evidence: -1, tamper: func(t *testing.T, block *types.FullSignedBlock) {
+			block.AveragePrice++
+		}},
+		{name: "evidence hash", kind: database.BrokenEvidenceHash, evidence: 1, tamper: func(t *testing.T, block *types.FullSignedBlock) {
+			block.Evidence = append([]types.Result(nil), block.Evidence...)
+			block.Evidence[1].Data.LastPrice++
+		}},
+		{name: "merkle root", kind: database.BrokenMerkleRoot, evidence: -1, tamper: func(t *testing.T, block *types.FullSignedBlock) {
+			block.Evidence = append([]types.Result(nil), block.Evidence[:2]...)
+		}},
+		{name: "previous hash", kind: database.BrokenPreviousHash, evidence: -1, tamper: func(t *testing.T, block *types.FullSignedBlock) {
+			block.PreviousHash = blocks[0].Hash
+			resign(t, block)
+		}},
+		{name: "previous address", kind: database.BrokenPreviousAddress, evidence: -1, tamper: func(t *testing.T, block *types.FullSignedBlock) {
+			block.PreviousAddress = ""
+			resign(t, block)
+		}},
+		{name: "signature", kind: database.BrokenSignature, evidence: -1, tamper: func(t *testing.T, block *types.FullSignedBlock) {
+			block.Signature = blocks[1].Signature
+		}},
+		{name: "signer", kind: database.BrokenSigner, evidence: -1, tamper: func(t *testing.T, block *types.FullSignedBlock) {
+			if err := block.Sign(newSigner(t)); err != nil {
+				t.Fatal(err)
+			}
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tampered := append([]types.FullSignedBlock(nil), blocks...)
+			test.tamper(t, &tampered[2])
+			chain, _ := chainOf(t, tampered)
+
+			report, err := chain.Verify()
+			var verificationErr *database.VerificationError
+			if !errors.As(err, &verificationErr) {
+				t.Fatalf("Verify() = %v, expected a broken %s", err, test.kind)
+			}
+			if verificationErr.Kind != test.kind || verificationErr.Height != 2 || verificationErr.Evidence != test.evidence {
+				t.Errorf("Verify() = %v, expected a broken %s at the block 2", err, test.kind)
+			}
+			if report.Blocks != 2 || report.LatestHash != blocks[1].Hash {
+				t.Errorf("Verify() reported %+v, expected the 2 blocks before the broken one", report)
+			}
+		})
+	}
+}
+
+func TestVerifyMissingBlock(t *testing.T) {
+
+	blocks := newSignedBlocks(t, newSigner(t), 4)
+	chain, _ := chainOf(t, append(blocks[:2:2], blocks[3]))
+
+	_, err := chain.Verify()
+	var verificationErr *database.VerificationError
+	if !errors.As(err, &verificationErr) || verificationErr.Kind != database.BrokenHeight || verificationErr.Height != 3 {
+		t.Errorf("Verify() = %v, expected a broken height at the block 3", err)
+	}
+}
+
+func TestVerifyLatest(t *testing.T) {
+
+	blocks := newSignedBlocks(t, newSigner(t), 4)
+	chain, store := chainOf(t, blocks)
+
+	// The latest pointer is left behind, as if the last block was written without it
+	latest, err := json.Marshal(blocks[2])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.StoreValue(database.LatestBlockKey, latest); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := chain.Verify()
+	var verificationErr *database.VerificationError
+	if !errors.As(err, &verificationErr) || verificationErr.Kind != database.BrokenLatest || verificationErr.Height != 3 {
+		t.Errorf("Verify() = %v, expected a broken latest pointer at the block 3", err)
+	}
+	if report.Blocks != 4 {
+		t.Errorf("Verify() reported %d blocks, expected 4", report.Blocks)
+	}
+}
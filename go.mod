@@ -3,6 +3,9 @@ module github.com/aquarelle-tech/darkmatter
 go 1.13
 
 require (
+	github.com/decred/dcrd/dcrec/secp256k1/v3 v3.0.0
 	github.com/dgraph-io/badger v1.6.0
 	github.com/gorilla/websocket v1.4.1
+	github.com/mattn/go-sqlite3 v1.14.6
+	golang.org/x/crypto v0.0.0-20200622213623-75b288015ac9
 )
@@ -0,0 +1,84 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+
+// Package identity manages the Ed25519 keypair of a node. The public key is the address of the node, and the
+// private key signs the blocks
+package identity
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DefaultKeyFile is the file of the unencrypted key of the node
+const DefaultKeyFile = "./chain/node.key"
+
+// Identity is the keypair of a node. It implements types.BlockSigner
+type Identity struct {
+	privateKey ed25519.PrivateKey
+}
+
+// Generate creates a new random identity
+func Generate() (*Identity, error) {
+	_, privateKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return &Identity{privateKey: privateKey}, nil
+}
+
+// FromSeed creates the identity of a 32 bytes seed (the private key of RFC 8032)
+func FromSeed(seed []byte) (*Identity, error) {
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("invalid seed length %d, expected %d bytes", len(seed), ed25519.SeedSize)
+	}
+	return &Identity{privateKey: ed25519.NewKeyFromSeed(seed)}, nil
+}
+
+// Load reads an identity from a file with the seed in hexadecimal
+func Load(path string) (*Identity, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	seed, err := hex.DecodeString(strings.TrimSpace(string(content)))
+	if err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %v", path, err)
+	}
+	return FromSeed(seed)
+}
+
+// Save writes the seed of the identity in a file, only readable by its owner
+func (i *Identity) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, []byte(hex.EncodeToString(i.Seed())+"\n"), 0600)
+}
+
+// Seed returns the private key of the identity, as defined in RFC 8032
+func (i *Identity) Seed() []byte {
+	return i.privateKey.Seed()
+}
+
+// PublicKey returns the public key of the identity
+func (i *Identity) PublicKey() ed25519.PublicKey {
+	return i.privateKey.Public().(ed25519.PublicKey)
+}
+
+// Address returns the address of the node: its public key in hexadecimal
+func (i *Identity) Address() string {
+	return hex.EncodeToString(i.PublicKey())
+}
+
+// SignHash signs the hash of a block
+func (i *Identity) SignHash(hash []byte) ([]byte, error) {
+	return ed25519.Sign(i.privateKey, hash), nil
+}
@@ -0,0 +1,97 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package identity
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/hex"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+// First test vector of RFC 8032, section 7.1
+const (
+	rfc8032Seed    = "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"
+	rfc8032Address = "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+)
+
+func TestFromSeed(t *testing.T) {
+
+	seed, _ := hex.DecodeString(rfc8032Seed)
+	identity, err := FromSeed(seed)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Address() != rfc8032Address {
+		t.Errorf("Address() = %s, expected %s", identity.Address(), rfc8032Address)
+	}
+	if !bytes.Equal(identity.Seed(), seed) {
+		t.Errorf("Seed() = %x, expected %s", identity.Seed(), rfc8032Seed)
+	}
+
+	if _, err := FromSeed(seed[1:]); err == nil {
+		t.Error("FromSeed() accepted a seed of 31 bytes")
+	}
+}
+
+func TestSignHash(t *testing.T) {
+
+	identity, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	hash := []byte("hash of a block")
+	signature, err := identity.SignHash(hash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !ed25519.Verify(identity.PublicKey(), hash, signature) {
+		t.Error("the signature is not valid for the public key")
+	}
+}
+
+func TestSaveLoad(t *testing.T) {
+
+	identity, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "chain", "node.key")
+	if err := identity.Save(path); err != nil {
+		t.Fatal(err)
+	}
+
+	loaded, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Address() != identity.Address() {
+		t.Errorf("Load() = %s, expected %s", loaded.Address(), identity.Address())
+	}
+}
+
+func TestLoadInvalid(t *testing.T) {
+
+	dir := t.TempDir()
+	tests := []struct {
+		name    string
+		content string
+	}{
+		{name: "not hexadecimal", content: "not a key\n"},
+		{name: "short seed", content: rfc8032Seed[2:] + "\n"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(dir, "node.key")
+			if err := ioutil.WriteFile(path, []byte(test.content), 0600); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := Load(path); err == nil {
+				t.Error("Load() accepted an invalid key file")
+			}
+		})
+	}
+}
@@ -0,0 +1,305 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package identity
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"golang.org/x/crypto/scrypt"
+)
+
+const (
+	// DefaultKeystoreDir is the directory of the keystore, under the chain data directory
+	DefaultKeystoreDir = "./chain/keystore"
+	// KeyFileVersion is the version of the format of the encrypted key files
+	KeyFileVersion = 1
+
+	activeKeyFile  = "active" // File with the address of the key that signs the blocks
+	keyFileSuffix  = ".json"  // Encrypted keys are stored in <address>.json
+	scryptKDF      = "scrypt" // Only supported key derivation function
+	aesGCMCipher   = "aes-256-gcm"
+	saltLength     = 32
+	derivedKeySize = 32 // AES-256
+)
+
+var (
+	// ErrWrongPassphrase is returned when a key can´t be decrypted with the passphrase
+	ErrWrongPassphrase = errors.New("wrong passphrase, or the key file is corrupted")
+	// ErrNoActiveKey is returned when the keystore has no key to sign the blocks
+	ErrNoActiveKey = errors.New("the keystore has no active key")
+	// ErrEmptyPassphrase is returned when a keystore is used without passphrase
+	ErrEmptyPassphrase = errors.New("the passphrase of the keystore can´t be empty")
+)
+
+// ScryptParams are the cost parameters of scrypt, used to derive the encryption key from the passphrase
+type ScryptParams struct {
+	N    int    `json:"n"`
+	R    int    `json:"r"`
+	P    int    `json:"p"`
+	Salt string `json:"salt"` // Hexadecimal
+}
+
+// DefaultScryptParams need 64 MB of memory and take about 0.2 seconds to derive a key
+var DefaultScryptParams = ScryptParams{N: 1 << 16, R: 8, P: 1}
+
+// EncryptedKey is the format of a key file of the keystore. The seed of the key is encrypted with AES-256-GCM,
+// with a key derived from the passphrase with scrypt. The address is authenticated with the seed, so it can´t be
+// changed without breaking the decryption
+type EncryptedKey struct {
+	Version    int          `json:"version"`
+	Address    string       `json:"address"`
+	KDF        string       `json:"kdf"`
+	KDFParams  ScryptParams `json:"kdfParams"`
+	Cipher     string       `json:"cipher"`
+	Nonce      string       `json:"nonce"`      // Hexadecimal
+	Ciphertext string       `json:"ciphertext"` // Hexadecimal, with the GCM tag
+}
+
+// Build the AES-GCM cipher of a passphrase
+func newKeyCipher(passphrase []byte, params ScryptParams) (cipher.AEAD, error) {
+	salt, err := hex.DecodeString(params.Salt)
+	if err != nil {
+		return nil, err
+	}
+	key, err := scrypt.Key(passphrase, salt, params.N, params.R, params.P, derivedKeySize)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// Encrypt protects the seed of the identity with a passphrase
+func Encrypt(identity *Identity, passphrase []byte, params ScryptParams) (EncryptedKey, error) {
+	return EncryptSecret(identity.Seed(), identity.Address(), passphrase, params)
+}
+
+// EncryptSecret protects the secret of a key of any kind with a passphrase. The address identifies the key
+func EncryptSecret(secret []byte, address string, passphrase []byte, params ScryptParams) (EncryptedKey, error) {
+
+	if len(passphrase) == 0 {
+		return EncryptedKey{}, ErrEmptyPassphrase
+	}
+	salt := make([]byte, saltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return EncryptedKey{}, err
+	}
+	params.Salt = hex.EncodeToString(salt)
+
+	aead, err := newKeyCipher(passphrase, params)
+	if err != nil {
+		return EncryptedKey{}, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return EncryptedKey{}, err
+	}
+
+	return EncryptedKey{
+		Version:    KeyFileVersion,
+		Address:    address,
+		KDF:        scryptKDF,
+		KDFParams:  params,
+		Cipher:     aesGCMCipher,
+		Nonce:      hex.EncodeToString(nonce),
+		Ciphertext: hex.EncodeToString(aead.Seal(nil, nonce, secret, []byte(address))),
+	}, nil
+}
+
+// Decrypt returns the identity protected by the key file
+func (key EncryptedKey) Decrypt(passphrase []byte) (*Identity, error) {
+
+	seed, err := key.DecryptSecret(passphrase)
+	if err != nil {
+		return nil, err
+	}
+	return identityOf(key.Address, seed)
+}
+
+// DecryptSecret returns the secret protected by the key file
+func (key EncryptedKey) DecryptSecret(passphrase []byte) ([]byte, error) {
+
+	if key.Version != KeyFileVersion || key.KDF != scryptKDF || key.Cipher != aesGCMCipher {
+		return nil, fmt.Errorf("unsupported key file: version %d, %s, %s", key.Version, key.KDF, key.Cipher)
+	}
+	aead, err := newKeyCipher(passphrase, key.KDFParams)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hex.DecodeString(key.Nonce)
+	if err != nil || len(nonce) != aead.NonceSize() {
+		return nil, ErrWrongPassphrase
+	}
+	ciphertext, err := hex.DecodeString(key.Ciphertext)
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	secret, err := aead.Open(nil, nonce, ciphertext, []byte(key.Address))
+	if err != nil {
+		return nil, ErrWrongPassphrase
+	}
+	return secret, nil
+}
+
+// Returns the identity of a seed, checking that it has the expected address
+func identityOf(address string, seed []byte) (*Identity, error) {
+	identity, err := FromSeed(seed)
+	if err != nil {
+		return nil, err
+	}
+	if identity.Address() != address {
+		return nil, ErrWrongPassphrase
+	}
+	return identity, nil
+}
+
+// Keystore is a directory with the encrypted keys of a node, all of them protected by the same passphrase. One of
+// them is the active key, which signs the new blocks. The old keys are kept after a rotation. The keys are Ed25519
+// identities, but the secrets of other kinds of keys can be stored in their own keystore, with their own addresses
+type Keystore struct {
+	Dir        string
+	Params     ScryptParams // Parameters used to encrypt the new keys
+	passphrase []byte
+}
+
+// OpenKeystore opens the keystore of a directory, created when the first key is stored
+func OpenKeystore(dir string, passphrase []byte) (*Keystore, error) {
+	if len(passphrase) == 0 {
+		return nil, ErrEmptyPassphrase
+	}
+	return &Keystore{Dir: dir, Params: DefaultScryptParams, passphrase: passphrase}, nil
+}
+
+// Child returns the keystore of a subdirectory, with the same passphrase. The keys of other kinds are stored in
+// their own keystore
+func (k *Keystore) Child(name string) *Keystore {
+	return &Keystore{Dir: filepath.Join(k.Dir, name), Params: k.Params, passphrase: k.passphrase}
+}
+
+// Path of the key file of an address
+func (k *Keystore) keyPath(address string) string {
+	return filepath.Join(k.Dir, address+keyFileSuffix)
+}
+
+// Import encrypts an identity and stores it in the keystore. It doesn´t change the active key
+func (k *Keystore) Import(identity *Identity) error {
+	return k.StoreSecret(identity.Address(), identity.Seed())
+}
+
+// StoreSecret encrypts the secret of a key and stores it in the keystore. It doesn´t change the active key
+func (k *Keystore) StoreSecret(address string, secret []byte) error {
+
+	key, err := EncryptSecret(secret, address, k.passphrase, k.Params)
+	if err != nil {
+		return err
+	}
+	bytes, err := json.MarshalIndent(key, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(k.Dir, 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(k.keyPath(key.Address), bytes, 0600)
+}
+
+// Generate creates a new identity and stores it in the keystore. It doesn´t change the active key
+func (k *Keystore) Generate() (*Identity, error) {
+	identity, err := Generate()
+	if err != nil {
+		return nil, err
+	}
+	return identity, k.Import(identity)
+}
+
+// Load decrypts the key of an address
+func (k *Keystore) Load(address string) (*Identity, error) {
+	seed, err := k.LoadSecret(address)
+	if err != nil {
+		return nil, err
+	}
+	return identityOf(address, seed)
+}
+
+// LoadSecret decrypts the secret of the key of an address
+func (k *Keystore) LoadSecret(address string) ([]byte, error) {
+
+	bytes, err := ioutil.ReadFile(k.keyPath(address))
+	if err != nil {
+		return nil, err
+	}
+	var key EncryptedKey
+	if err := json.Unmarshal(bytes, &key); err != nil {
+		return nil, fmt.Errorf("invalid key file for %s: %v", address, err)
+	}
+	if key.Address != address {
+		return nil, fmt.Errorf("the key file of %s has the address %s", address, key.Address)
+	}
+
+	return key.DecryptSecret(k.passphrase)
+}
+
+// Addresses returns the addresses of the keys stored in the keystore
+func (k *Keystore) Addresses() ([]string, error) {
+
+	files, err := ioutil.ReadDir(k.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var addresses []string
+	for _, file := range files {
+		if !file.IsDir() && strings.HasSuffix(file.Name(), keyFileSuffix) {
+			addresses = append(addresses, strings.TrimSuffix(file.Name(), keyFileSuffix))
+		}
+	}
+	sort.Strings(addresses)
+
+	return addresses, nil
+}
+
+// ActiveAddress returns the address of the active key, or ErrNoActiveKey
+func (k *Keystore) ActiveAddress() (string, error) {
+	bytes, err := ioutil.ReadFile(filepath.Join(k.Dir, activeKeyFile))
+	if os.IsNotExist(err) {
+		return "", ErrNoActiveKey
+	}
+	return strings.TrimSpace(string(bytes)), err
+}
+
+// Active decrypts the active key, or returns ErrNoActiveKey
+func (k *Keystore) Active() (*Identity, error) {
+	address, err := k.ActiveAddress()
+	if err != nil {
+		return nil, err
+	}
+	return k.Load(address)
+}
+
+// SetActive changes the key that signs the new blocks. The key must be in the keystore, and it is decrypted to
+// check the passphrase
+func (k *Keystore) SetActive(address string) error {
+	if _, err := k.LoadSecret(address); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(k.Dir, activeKeyFile), []byte(address+"\n"), 0600)
+}
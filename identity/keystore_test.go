@@ -0,0 +1,212 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package identity
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+)
+
+// Cheap scrypt parameters, so the tests run fast
+var testScryptParams = ScryptParams{N: 1 << 10, R: 8, P: 1}
+
+// Opens an empty keystore in a temporary directory
+func newTestKeystore(t *testing.T) *Keystore {
+	t.Helper()
+
+	keystore, err := OpenKeystore(t.TempDir(), []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keystore.Params = testScryptParams
+	return keystore
+}
+
+func TestEncryptDecrypt(t *testing.T) {
+
+	identity, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := Encrypt(identity, []byte("passphrase"), testScryptParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key.Address != identity.Address() {
+		t.Errorf("the key file has the address %s, expected %s", key.Address, identity.Address())
+	}
+
+	decrypted, err := key.Decrypt([]byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decrypted.Address() != identity.Address() {
+		t.Errorf("Decrypt() = %s, expected %s", decrypted.Address(), identity.Address())
+	}
+
+	if _, err := Encrypt(identity, nil, testScryptParams); err != ErrEmptyPassphrase {
+		t.Errorf("Encrypt() without passphrase = %v, expected %v", err, ErrEmptyPassphrase)
+	}
+}
+
+func TestDecryptRejected(t *testing.T) {
+
+	identity, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key, err := Encrypt(identity, []byte("passphrase"), testScryptParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, _ := hex.DecodeString(key.Ciphertext)
+	ciphertext[0] ^= 1
+
+	tests := []struct {
+		name       string
+		passphrase string
+		tamper     func(key *EncryptedKey)
+	}{
+		{name: "wrong passphrase", passphrase: "wrong", tamper: func(key *EncryptedKey) {}},
+		{name: "tampered ciphertext", passphrase: "passphrase", tamper: func(key *EncryptedKey) {
+			key.Ciphertext = hex.EncodeToString(ciphertext)
+		}},
+		{name: "tampered nonce", passphrase: "passphrase", tamper: func(key *EncryptedKey) {
+			key.Nonce = key.Nonce[2:] + key.Nonce[:2]
+		}},
+		{name: "changed address", passphrase: "passphrase", tamper: func(key *EncryptedKey) {
+			key.Address = other.Address()
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tampered := key
+			test.tamper(&tampered)
+			if _, err := tampered.Decrypt([]byte(test.passphrase)); err != ErrWrongPassphrase {
+				t.Errorf("Decrypt() = %v, expected %v", err, ErrWrongPassphrase)
+			}
+		})
+	}
+}
+
+func TestKeystore(t *testing.T) {
+
+	keystore := newTestKeystore(t)
+	if _, err := keystore.Active(); err != ErrNoActiveKey {
+		t.Fatalf("Active() of an empty keystore = %v, expected %v", err, ErrNoActiveKey)
+	}
+
+	imported, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keystore.Import(imported); err != nil {
+		t.Fatal(err)
+	}
+	generated, err := keystore.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addresses, err := keystore.Addresses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(addresses) != 2 {
+		t.Fatalf("Addresses() = %v, expected 2 keys", addresses)
+	}
+	loaded, err := keystore.Load(imported.Address())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Address() != imported.Address() {
+		t.Errorf("Load() = %s, expected %s", loaded.Address(), imported.Address())
+	}
+
+	for _, identity := range []*Identity{imported, generated} {
+		if err := keystore.SetActive(identity.Address()); err != nil {
+			t.Fatal(err)
+		}
+		active, err := keystore.Active()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if active.Address() != identity.Address() {
+			t.Errorf("Active() = %s, expected %s", active.Address(), identity.Address())
+		}
+	}
+}
+
+func TestKeystoreWrongPassphrase(t *testing.T) {
+
+	keystore := newTestKeystore(t)
+	identity, err := keystore.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := OpenKeystore(keystore.Dir, []byte("wrong"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := other.Load(identity.Address()); err != ErrWrongPassphrase {
+		t.Errorf("Load() = %v, expected %v", err, ErrWrongPassphrase)
+	}
+	if err := other.SetActive(identity.Address()); err != ErrWrongPassphrase {
+		t.Errorf("SetActive() = %v, expected %v", err, ErrWrongPassphrase)
+	}
+	if _, err := OpenKeystore(keystore.Dir, nil); err != ErrEmptyPassphrase {
+		t.Errorf("OpenKeystore() without passphrase = %v, expected %v", err, ErrEmptyPassphrase)
+	}
+}
+
+func TestKeystoreTamperedFile(t *testing.T) {
+
+	keystore := newTestKeystore(t)
+	identity, err := keystore.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A key file copied to the name of another address
+	content, err := ioutil.ReadFile(keystore.keyPath(identity.Address()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keystore.keyPath(other.Address()), content, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keystore.Load(other.Address()); err == nil {
+		t.Error("Load() accepted the key file of another address")
+	}
+
+	// A key file with a tampered ciphertext
+	var key EncryptedKey
+	if err := json.Unmarshal(content, &key); err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, _ := hex.DecodeString(key.Ciphertext)
+	ciphertext[len(ciphertext)-1] ^= 1
+	key.Ciphertext = hex.EncodeToString(ciphertext)
+	tampered, err := json.Marshal(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(keystore.keyPath(identity.Address()), tampered, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := keystore.Load(identity.Address()); err != ErrWrongPassphrase {
+		t.Errorf("Load() = %v, expected %v", err, ErrWrongPassphrase)
+	}
+}
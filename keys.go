@@ -0,0 +1,216 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/aquarelle-tech/darkmatter/attestation"
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/identity"
+	"github.com/aquarelle-tech/darkmatter/mapreduce"
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// PassphraseEnv is the environment variable with the passphrase of the keystore, when no file is set
+const PassphraseEnv = "DARKMATTER_PASSPHRASE"
+
+// Opens the keystore with the passphrase of the file, or of the PassphraseEnv variable
+func openKeystore(dir string, passphraseFile string) (*identity.Keystore, error) {
+
+	passphrase := []byte(os.Getenv(PassphraseEnv))
+	if passphraseFile != "" {
+		content, err := ioutil.ReadFile(passphraseFile)
+		if err != nil {
+			return nil, err
+		}
+		passphrase = bytes.TrimRight(content, "\r\n")
+	}
+	if len(passphrase) == 0 {
+		return nil, fmt.Errorf("the keystore needs a passphrase: set it with -passphrase-file or %s", PassphraseEnv)
+	}
+
+	return identity.OpenKeystore(dir, passphrase)
+}
+
+// Returns the active key of the node. On the first run the keystore is empty: the unencrypted key of the older
+// nodes is imported, or a new key is created
+func nodeIdentity(keystore *identity.Keystore) (*identity.Identity, error) {
+
+	node, err := keystore.Active()
+	if err != identity.ErrNoActiveKey {
+		return node, err
+	}
+	if addresses, err := keystore.Addresses(); err != nil || len(addresses) > 0 {
+		return nil, fmt.Errorf("the keystore %s has no active key", keystore.Dir)
+	}
+
+	node, err = identity.Load(identity.DefaultKeyFile)
+	switch {
+	case err == nil:
+		log.Printf("Importing the unencrypted key %s. Delete the file once the keystore is backed up", identity.DefaultKeyFile)
+	case os.IsNotExist(err):
+		log.Println("Creating a new key for the node")
+		node, err = identity.Generate()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := keystore.Import(node); err != nil {
+		return nil, err
+	}
+	return node, keystore.SetActive(node.Address())
+}
+
+// Stores a key in the keystore. It becomes the active key if there is none
+func storeKey(keystore *identity.Keystore, key *identity.Identity) bool {
+
+	if err := keystore.Import(key); err != nil {
+		log.Printf("Unable to store the key: %v", err)
+		return false
+	}
+	if _, err := keystore.ActiveAddress(); err == identity.ErrNoActiveKey {
+		if err := keystore.SetActive(key.Address()); err != nil {
+			log.Printf("Unable to activate the key: %v", err)
+			return false
+		}
+		log.Printf("The key %s is the active key of the node", key.Address())
+	}
+	fmt.Println(key.Address())
+
+	return true
+}
+
+// Creates a new key in the keystore and prints its address
+func generateKey(keystore *identity.Keystore) bool {
+
+	key, err := identity.Generate()
+	if err != nil {
+		log.Printf("Unable to create a key: %v", err)
+		return false
+	}
+	return storeKey(keystore, key)
+}
+
+// Imports an unencrypted key file, with the seed in hexadecimal, and prints its address
+func importKey(keystore *identity.Keystore, file string) bool {
+
+	key, err := identity.Load(file)
+	if err != nil {
+		log.Printf("Unable to read the key: %v", err)
+		return false
+	}
+	return storeKey(keystore, key)
+}
+
+// Writes the unencrypted active key to a file, with the seed in hexadecimal
+func exportKey(keystore *identity.Keystore, file string) bool {
+
+	key, err := keystore.Active()
+	if err != nil {
+		log.Printf("Unable to read the active key: %v", err)
+		return false
+	}
+	if err := key.Save(file); err != nil {
+		log.Printf("Unable to export the key: %v", err)
+		return false
+	}
+	log.Printf("The key %s is exported to %s", key.Address(), file)
+
+	return true
+}
+
+// Replaces the active key with another key of the keystore. Each chain gets a rotation block, signed by the active
+// key, which announces the new one. The new key is activated when all the chains are rotated, so the command can
+// be run again if it fails. It must include all the markets of the node
+func rotateKey(keystore *identity.Keystore, address string, pipelines []mapreduce.PipelineConfig, options []database.Option) bool {
+
+	current, err := keystore.Active()
+	if err != nil {
+		log.Printf("Unable to read the active key: %v", err)
+		return false
+	}
+	next, err := keystore.Load(address)
+	if err != nil {
+		log.Printf("Unable to read the key %s: %v", address, err)
+		return false
+	}
+
+	rotate := func(market types.Market, chain *database.BlockChain) error {
+		block, err := chain.RotateSigner(next)
+		if err != nil {
+			return err
+		}
+		if block != nil {
+			log.Printf("The key of %s is rotated in the block %d (%s)", market, block.Height, block.Hash)
+		}
+		return nil
+	}
+	if !forEachChain(pipelines, append(options, database.WithSigner(current)), rotate) {
+		return false
+	}
+
+	if err := keystore.SetActive(next.Address()); err != nil {
+		log.Printf("Unable to activate the key %s: %v", next.Address(), err)
+		return false
+	}
+	log.Printf("The active key is %s", next.Address())
+
+	return true
+}
+
+// Prints the keys that signed each chain
+func printKeyHistory(market types.Market, chain *database.BlockChain) error {
+
+	periods, err := chain.KeyHistory()
+	if err != nil {
+		return err
+	}
+	for _, period := range periods {
+		address := period.Address
+		if address == "" {
+			address = "(unsigned)"
+		}
+		fmt.Printf("%s\t%d\t%d\t%s\n", market.Ticker(), period.From, period.To, address)
+	}
+
+	return nil
+}
+
+// Returns the attester of the secp256k1 key in the attestation keystore, inside the keystore of the node. The key
+// is created on the first run
+func loadAttester(keystore *identity.Keystore, domain attestation.Domain) (*attestation.Attester, error) {
+	return attestation.LoadOrCreate(keystore.Child(attestation.KeystoreDir), domain)
+}
+
+// Imports a secp256k1 private key file, in hexadecimal, as the attestation key and prints its address
+func importAttestationKey(keystore *identity.Keystore, file string, domain attestation.Domain) bool {
+
+	content, err := ioutil.ReadFile(file)
+	if err != nil {
+		log.Printf("Unable to read the key: %v", err)
+		return false
+	}
+	privateKey, err := hex.DecodeString(strings.TrimPrefix(strings.TrimSpace(string(content)), "0x"))
+	if err != nil {
+		log.Printf("Invalid key file %s: %v", file, err)
+		return false
+	}
+
+	attester, err := attestation.Import(keystore.Child(attestation.KeystoreDir), privateKey, domain)
+	if err != nil {
+		log.Printf("Unable to import the attestation key: %v", err)
+		return false
+	}
+	fmt.Println(attester.Address())
+
+	return true
+}
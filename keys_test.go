@@ -0,0 +1,184 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/aquarelle-tech/darkmatter/identity"
+)
+
+// Opens an empty keystore in a temporary directory, with cheap scrypt parameters
+func newTestKeystore(t *testing.T, dir string) *identity.Keystore {
+	t.Helper()
+
+	keystore, err := identity.OpenKeystore(filepath.Join(dir, "keystore"), []byte("passphrase"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	keystore.Params = identity.ScryptParams{N: 1 << 10, R: 8, P: 1}
+	return keystore
+}
+
+// Runs the test in a temporary directory, where the node finds its ./chain directory
+func inTempDir(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+func TestImportExportKey(t *testing.T) {
+
+	dir := t.TempDir()
+	keystore := newTestKeystore(t, dir)
+	key, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	imported := filepath.Join(dir, "imported.key")
+	if err := key.Save(imported); err != nil {
+		t.Fatal(err)
+	}
+
+	if !importKey(keystore, imported) {
+		t.Fatal("importKey() failed")
+	}
+	// The first key imported becomes the active key
+	active, err := keystore.Active()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if active.Address() != key.Address() {
+		t.Errorf("the active key is %s, expected %s", active.Address(), key.Address())
+	}
+
+	// A second key is stored, but the active key doesn´t change
+	other, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := other.Save(imported); err != nil {
+		t.Fatal(err)
+	}
+	if !importKey(keystore, imported) {
+		t.Fatal("importKey() failed")
+	}
+	if address, err := keystore.ActiveAddress(); err != nil || address != key.Address() {
+		t.Errorf("the active key is %s (%v), expected %s", address, err, key.Address())
+	}
+
+	exported := filepath.Join(dir, "exported.key")
+	if !exportKey(keystore, exported) {
+		t.Fatal("exportKey() failed")
+	}
+	loaded, err := identity.Load(exported)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loaded.Address() != key.Address() {
+		t.Errorf("the exported key is %s, expected %s", loaded.Address(), key.Address())
+	}
+
+	if importKey(keystore, filepath.Join(dir, "missing.key")) {
+		t.Error("importKey() accepted a missing file")
+	}
+}
+
+func TestExportWithoutActiveKey(t *testing.T) {
+
+	dir := t.TempDir()
+	exported := filepath.Join(dir, "exported.key")
+	if exportKey(newTestKeystore(t, dir), exported) {
+		t.Error("exportKey() succeeded without an active key")
+	}
+	if _, err := os.Stat(exported); !os.IsNotExist(err) {
+		t.Errorf("the key file was written: %v", err)
+	}
+}
+
+func TestNodeIdentityLegacyKey(t *testing.T) {
+
+	dir := inTempDir(t)
+	legacy, err := identity.Generate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := legacy.Save(identity.DefaultKeyFile); err != nil {
+		t.Fatal(err)
+	}
+
+	keystore := newTestKeystore(t, dir)
+	node, err := nodeIdentity(keystore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Address() != legacy.Address() {
+		t.Errorf("nodeIdentity() = %s, expected the legacy key %s", node.Address(), legacy.Address())
+	}
+	if address, err := keystore.ActiveAddress(); err != nil || address != legacy.Address() {
+		t.Errorf("the active key is %s (%v), expected %s", address, err, legacy.Address())
+	}
+
+	// Once imported, the keystore is used even if the legacy file changes
+	if err := ioutil.WriteFile(identity.DefaultKeyFile, []byte("not a key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	node, err = nodeIdentity(keystore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Address() != legacy.Address() {
+		t.Errorf("nodeIdentity() = %s, expected %s", node.Address(), legacy.Address())
+	}
+}
+
+func TestNodeIdentity(t *testing.T) {
+
+	dir := inTempDir(t)
+
+	// Without the legacy key, a new key is created
+	keystore := newTestKeystore(t, dir)
+	node, err := nodeIdentity(keystore)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if address, err := keystore.ActiveAddress(); err != nil || address != node.Address() {
+		t.Errorf("the active key is %s (%v), expected %s", address, err, node.Address())
+	}
+	if _, err := os.Stat(identity.DefaultKeyFile); !os.IsNotExist(err) {
+		t.Errorf("the unencrypted key was written: %v", err)
+	}
+
+	// An invalid legacy key isn´t replaced by a new one
+	if err := os.MkdirAll(filepath.Dir(identity.DefaultKeyFile), 0700); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(identity.DefaultKeyFile, []byte("not a key\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nodeIdentity(newTestKeystore(t, filepath.Join(dir, "invalid"))); err == nil {
+		t.Error("nodeIdentity() ignored an invalid legacy key")
+	}
+
+	// A keystore with keys, but none of them active, needs an explicit choice
+	keystore = newTestKeystore(t, filepath.Join(dir, "inactive"))
+	if _, err := keystore.Generate(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := nodeIdentity(keystore); err == nil {
+		t.Error("nodeIdentity() chose a key of a keystore without active key")
+	}
+}
@@ -6,24 +6,62 @@
 package mapreduce
 
 import (
+	"context"
+	"fmt"
+	"log"
 	"sync"
 	"time"
 
+	"github.com/aquarelle-tech/darkmatter/attestation"
+	"github.com/aquarelle-tech/darkmatter/cryptoindex"
 	"github.com/aquarelle-tech/darkmatter/database"
 	"github.com/aquarelle-tech/darkmatter/types"
 )
 
 const (
-	// How many seconds between a call and another one
-	DELAY_BETWEEN_CRAWLS = 2 * time.Second
+	// Default time between the start of a round and the next one. The rounds are aligned to the wall clock
+	DEFAULT_ROUND_INTERVAL = 5 * time.Second
 
-	// BlockchainFileLocation is the directory where to store the database for the node
+	// Maximum time to wait for the answer of a crawler
+	DEFAULT_CRAWL_TIMEOUT = 5 * time.Second
+
+	// Blocks that the publication channel can hold while the listeners are busy. When it is full, the new blocks
+	// are stored but not published
+	DEFAULT_PUBLICATION_BUFFER = 64
+
+	// BlockchainFileLocation is the directory where to store the databases for the node. Each market has its own
+	// chain in a subdirectory named as their ticker
 	BlockchainFileLocation = "./chain/stor"
-	MainBlockChainName     = "main"
 )
 
-// PublicBlockDatabase is the main instance to manage the database
-var PublicBlockDatabase *database.BlockChain = database.NewBlockChain(MainBlockChainName, BlockchainFileLocation)
+// Memo of the blocks from rounds finished after their deadline
+const OverrunMemo = "overrun"
+
+// RoundStatus describes the outcome of the latest map-reduce round
+type RoundStatus struct {
+	Round     time.Time `json:"round"` // Boundary where the round started
+	Timestamp time.Time `json:"timestamp"`
+	Overrun   bool      `json:"overrun"` // The round finished after the start of the next one
+	Published bool      `json:"published"`
+	Height    uint64    `json:"height"`           // Height of the published block, if any
+	Reason    string    `json:"reason,omitempty"` // Why the block was not published
+	Error     string    `json:"error,omitempty"`
+	// Blocks of the processor stored but not published because the publication channel was full
+	DroppedPublications uint64 `json:"droppedPublications"`
+}
+
+// Publication is a new block sent to the listeners, with the attestation of its price when they are enabled
+type Publication struct {
+	Block       types.FullSignedBlock
+	Attestation *attestation.Attestation
+}
+
+// Shared by all the copies of a processor
+type roundState struct {
+	mutex   sync.RWMutex
+	status  RoundStatus
+	dropped uint64
+}
 
 type Processor struct {
 	// Channels to build the worker pool
@@ -31,30 +69,84 @@ type Processor struct {
 	Results  chan types.Result
 
 	Directory       []types.PriceEvidenceCrawler
-	QuotedCurrency  string
-	PublicationChan chan types.FullSignedBlock
+	Market          types.Market
+	Chain           *database.BlockChain
+	PublicationChan chan Publication
+	// Signs the attestations of the published blocks. Without attester, they are disabled
+	Attester *attestation.Attester
+
+	// Wall-clock boundaries where the rounds start
+	Schedule Schedule
+	// When true, the rounds finished after their deadline don´t produce a block. If not, the block is marked
+	// with the OverrunMemo
+	SkipOverruns bool
+
+	// Rates to convert the sources quoted in other currencies. Sources without a rate are excluded
+	QuoteRates cryptoindex.QuoteRates
+	// Rule to discard the sources too far from the consensus of a round
+	OutlierPolicy cryptoindex.OutlierPolicy
+	// Maximum time to wait for each crawler. After it, the source is stored with an error
+	CrawlTimeout time.Duration
+	// Minimum evidence to mint a block
+	QuorumPolicy cryptoindex.QuorumPolicy
+
+	latestRound *roundState
+	loop        *loopState
+}
+
+// Controls the main loop, shared by all the copies of a processor
+type loopState struct {
+	mutex   sync.Mutex
+	started bool
+	quit    chan struct{}
+	done    chan struct{}
 }
 
-func NewMapReduceProcessor(directory []types.PriceEvidenceCrawler, quotedCurrency string, publicationChan chan types.FullSignedBlock) Processor {
+func NewMapReduceProcessor(directory []types.PriceEvidenceCrawler, market types.Market, chain *database.BlockChain, publicationChan chan Publication) Processor {
 	// Channels to build the worker pool
 	return Processor{
 		Directory:       directory,
-		QuotedCurrency:  quotedCurrency,
+		Market:          market,
+		Chain:           chain,
 		PublicationChan: publicationChan,
+		Schedule:        NewSchedule(DEFAULT_ROUND_INTERVAL),
+		SkipOverruns:    true,
+		QuoteRates:      cryptoindex.DefaultQuoteRates,
+		OutlierPolicy:   cryptoindex.DefaultOutlierPolicy,
+		CrawlTimeout:    DEFAULT_CRAWL_TIMEOUT,
+		QuorumPolicy:    cryptoindex.DefaultQuorumPolicy,
+		latestRound:     &roundState{},
+		loop:            &loopState{quit: make(chan struct{}), done: make(chan struct{})},
 	}
 }
 
-// Collect the results
-func (p Processor) mapJob(wg *sync.WaitGroup) {
+// LatestRound returns the status of the latest round finished by the processor
+func (p Processor) LatestRound() RoundStatus {
+	p.latestRound.mutex.RLock()
+	defer p.latestRound.mutex.RUnlock()
+
+	status := p.latestRound.status
+	status.DroppedPublications = p.latestRound.dropped
+	return status
+}
 
-	// The process should also be called async
-	internalChan := make(chan types.QuotePriceInfo)
+// Save the outcome of a round
+func (p Processor) setLatestRound(status RoundStatus) {
+	p.latestRound.mutex.Lock()
+	defer p.latestRound.mutex.Unlock()
+
+	p.latestRound.status = status
+}
+
+// Collect the results. The context is done at the deadline of the round
+func (p Processor) mapJob(roundCtx context.Context, wg *sync.WaitGroup) {
 
 	for job := range p.DataJobs {
-		// Get the data
-		go job.DataCrawler.Crawl(job.Quote, internalChan)
+		// Get the data. The crawler is not waited longer than the timeout
+		ctx, cancel := context.WithTimeout(roundCtx, p.CrawlTimeout)
+		data, err := crawl(ctx, job)
+		cancel()
 
-		data := <-internalChan
 		result := types.Result{
 			Data:        data,
 			Ticker:      job.DataCrawler.GetTicker(),
@@ -62,22 +154,53 @@ func (p Processor) mapJob(wg *sync.WaitGroup) {
 			Timestamp:   time.Now().Unix(),
 			CrawlerName: job.DataCrawler.GetName(),
 		}
+		if err != nil {
+			log.Printf("Error crawling %s: %v", result.CrawlerName, err)
+			result.HasError = true
+			result.Error = err.Error()
+		}
 		result.CreateHash()
 
 		// Send the result to the queue
 		p.Results <- result
 	}
 
-	close(internalChan)
 	wg.Done()
 }
 
-func (p Processor) createWorkerPool(size int) {
+// Call the crawler of a job. The answer is not waited after the context is done, even if the crawler ignores it,
+// and the panics are turned in errors, so one bad source can´t stop the round
+func crawl(ctx context.Context, job types.GetDataJob) (types.QuotePriceInfo, error) {
+	type answer struct {
+		data types.QuotePriceInfo
+		err  error
+	}
+	answers := make(chan answer, 1)
+
+	go func() {
+		defer func() {
+			if r := recover(); r != nil {
+				answers <- answer{err: fmt.Errorf("crawler failed: %v", r)}
+			}
+		}()
+		data, err := job.DataCrawler.Crawl(ctx)
+		answers <- answer{data: data, err: err}
+	}()
+
+	select {
+	case a := <-answers:
+		return a.data, a.err
+	case <-ctx.Done():
+		return types.QuotePriceInfo{}, ctx.Err()
+	}
+}
+
+func (p Processor) createWorkerPool(ctx context.Context, size int) {
 	var wg sync.WaitGroup
 
 	for i := 0; i < size; i++ {
 		wg.Add(1)
-		go p.mapJob(&wg)
+		go p.mapJob(ctx, &wg)
 	}
 	wg.Wait()
 
@@ -88,7 +211,6 @@ func (p Processor) createWorkerPool(size int) {
 func (p Processor) allocateJobs(poolSize int) {
 	for i := 0; i < poolSize; i++ {
 		newJob := types.GetDataJob{
-			Quote:       p.QuotedCurrency,
 			DataCrawler: p.Directory[i], // Get the crawler
 		}
 		p.DataJobs <- newJob
@@ -97,57 +219,179 @@ func (p Processor) allocateJobs(poolSize int) {
 	close(p.DataJobs)
 }
 
-// Execute the Reduce stage. Get all the data crawled from the sources and generates an aggregate index
-func (p Processor) reduceJobs(poolSize int) {
-	var totalVolume float64
-	// var totalQuoted float64
-	var totalPrice float64
-
-	//====================  HACK: This code must be replaced with the real algorithm to calculate the avg price ======
-	ticker := "BTCUSD"
+// Execute the Reduce stage. Get all the data crawled from the sources and generates an aggregate index. The block
+// is stamped with the boundary where the round started
+func (p Processor) reduceJobs(round time.Time) {
+	ticker := p.Market.Ticker()
 
 	var sources []types.Result
-	// NOTE: Instead of sum or any other calculation, the code will below will use a value from any of the providers, temporarly
 	for result := range p.Results {
 		sources = append(sources, result)
 	}
 
-	// Get the first
-	totalVolume = sources[0].Data.Volume
-	totalPrice = sources[0].Data.HighPrice
-	//====================================================================================================================
+	status := RoundStatus{Round: round, Timestamp: time.Now()}
+	sources, err := cryptoindex.RouteQuotes(sources, p.Market.Quote, p.QuoteRates)
+	if err != nil {
+		log.Printf("Unable to route the quotes for %s in this round: %v", ticker, err)
+		status.Error = err.Error()
+		p.setLatestRound(status)
+		return
+	}
+	sources, err = cryptoindex.RejectOutliers(sources, p.OutlierPolicy)
+	if err != nil {
+		log.Printf("Unable to reject the outliers for %s in this round: %v", ticker, err)
+		status.Error = err.Error()
+		p.setLatestRound(status)
+		return
+	}
+	memo := ""
+	if status.Timestamp.After(p.Schedule.Deadline(round)) {
+		status.Overrun = true
+		if p.SkipOverruns {
+			log.Printf("No block for %s in the round of %s: the round overran", ticker, round.Format(time.RFC3339))
+			status.Reason = OverrunMemo
+			p.setLatestRound(status)
+			return
+		}
+		memo = OverrunMemo
+	}
+
+	// Without quorum, there is no block in this round
+	if err := cryptoindex.CheckQuorum(sources, p.QuorumPolicy); err != nil {
+		log.Printf("No block for %s in this round: %v", ticker, err)
+		status.Error = err.Error()
+		if quorumErr, ok := err.(*cryptoindex.QuorumError); ok {
+			status.Reason = quorumErr.Reason
+		}
+		p.setLatestRound(status)
+		return
+	}
+
+	index, err := cryptoindex.Calculate(sources)
+	if err != nil {
+		log.Printf("Unable to calculate the index for %s in this round: %v", ticker, err)
+		status.Error = err.Error()
+		p.setLatestRound(status)
+		return
+	}
+	log.Printf("Index calculated for %s: price=%f, volume=%f, weights=%v", ticker, index.Price, index.Volume, index.Weights)
 
 	// Create a message to send to service´s listeners
-	newMsg := PublicBlockDatabase.NewFullSignedBlock(
+	newMsg, err := p.Chain.NewFullSignedBlock(
 		ticker,
-		totalPrice,  // Average price
-		totalVolume, // High price
+		uint64(round.Unix()),
+		index.Price,  // Volume-weighted median price
+		index.Volume, // Total volume of the sources
 		sources,
-		"", // TODO: Add the memo info, if any
+		memo,
 	)
+	if err != nil {
+		log.Printf("Unable to store the block for %s in this round: %v", ticker, err)
+		status.Error = err.Error()
+		p.setLatestRound(status)
+		return
+	}
+	status.Published = true
+	status.Height = newMsg.Height
+	p.setLatestRound(status)
 
-	p.PublicationChan <- newMsg
+	p.publish(newMsg)
 }
 
-func (p Processor) mapReduceLoop() {
+// Send a block to the listeners, attested once for all of them. The publication doesn´t hold the next round: when
+// the channel is full, the block is dropped from the publication and counted. It is still in the chain
+func (p Processor) publish(block types.FullSignedBlock) {
+
+	publication := Publication{Block: block}
+	if p.Attester != nil {
+		if signed, err := p.Attester.Attest(block); err == nil {
+			publication.Attestation = &signed
+		} else {
+			log.Printf("Unable to attest the block %s: %v", block.Hash, err)
+		}
+	}
+
+	select {
+	case p.PublicationChan <- publication:
+	default:
+		p.latestRound.mutex.Lock()
+		p.latestRound.dropped++
+		p.latestRound.mutex.Unlock()
+		log.Printf("The publication channel is full, the block %d of %s is not published", block.Height, block.Ticker)
+	}
+}
+
+// Run a full round started at a boundary. The crawlers are cancelled before the deadline of the round, leaving a
+// fifth of the interval to the reduce stage
+func (p Processor) runRound(round time.Time) {
 	poolSize := len(p.Directory)
+
+	crawlDeadline := p.Schedule.Deadline(round).Add(-p.Schedule.Interval / 5)
+	ctx, cancel := context.WithDeadline(context.Background(), crawlDeadline)
+	defer cancel()
+
+	// Channels to build the worker pool
+	p.DataJobs = make(chan types.GetDataJob, poolSize)
+	p.Results = make(chan types.Result, poolSize)
+
+	// Create the jobs an launch the process to create
+	reduced := make(chan struct{})
+	go p.allocateJobs(poolSize)
+	go func() {
+		p.reduceJobs(round)
+		close(reduced)
+	}()
+
+	p.createWorkerPool(ctx, poolSize)
+	<-reduced
+}
+
+func (p Processor) mapReduceLoop() {
+	defer close(p.loop.done)
+
+	next := p.Schedule.Next(time.Now())
 	for {
-		// Channels to build the worker pool
-		p.DataJobs = make(chan types.GetDataJob, poolSize)
-		p.Results = make(chan types.Result, poolSize)
+		// Wait for the next boundary
+		select {
+		case <-time.After(time.Until(next)):
+		case <-p.loop.quit:
+			return
+		}
 
-		// Create the jobs an launch the process to create
-		go p.allocateJobs(poolSize)
-		go p.reduceJobs(poolSize)
+		round := next
+		p.runRound(round)
 
-		p.createWorkerPool(poolSize)
-		// and wait to request a new block of daya
-		time.Sleep(DELAY_BETWEEN_CRAWLS)
+		next = p.Schedule.Deadline(round)
+		if missed := p.Schedule.Missed(round, time.Now()); missed > 0 {
+			log.Printf("The round of %s at %s overran, skipping %d rounds", p.Market.Ticker(), round.Format(time.RFC3339), missed)
+			next = p.Schedule.Next(time.Now())
+		}
 	}
 }
 
 // Launch the main loop of the map-reduce processor. The method verify the data before to launch the main loop
 func (p Processor) Initialize() {
 	//TODO: Validate the parameterized data
-	go p.mapReduceLoop()
+	p.loop.mutex.Lock()
+	defer p.loop.mutex.Unlock()
+
+	if !p.loop.started {
+		p.loop.started = true
+		go p.mapReduceLoop()
+	}
+}
+
+// Stop ends the main loop after the current round, and waits for it
+func (p Processor) Stop() {
+	p.loop.mutex.Lock()
+	defer p.loop.mutex.Unlock()
+
+	select {
+	case <-p.loop.quit: // Already stopped
+	default:
+		close(p.loop.quit)
+	}
+	if p.loop.started {
+		<-p.loop.done
+	}
 }
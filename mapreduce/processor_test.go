@@ -0,0 +1,248 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package mapreduce
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aquarelle-tech/darkmatter/cryptoindex"
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// A crawler with a fixed answer. It can fail, panic, or take its time to answer
+type fakeCrawler struct {
+	name      string
+	price     float64
+	volume    float64
+	err       error
+	panics    bool
+	delay     time.Duration
+	ignoreCtx bool // Keep sleeping after the context is done
+}
+
+func (c fakeCrawler) Crawl(ctx context.Context) (types.QuotePriceInfo, error) {
+	if c.panics {
+		panic("unexpected answer")
+	}
+	if c.delay > 0 {
+		if c.ignoreCtx {
+			time.Sleep(c.delay)
+		} else {
+			select {
+			case <-time.After(c.delay):
+			case <-ctx.Done():
+				return types.QuotePriceInfo{}, ctx.Err()
+			}
+		}
+	}
+	if c.err != nil {
+		return types.QuotePriceInfo{}, c.err
+	}
+
+	return types.QuotePriceInfo{LastPrice: c.price, Volume: c.volume, QuoteCurrency: "USD"}, nil
+}
+
+func (c fakeCrawler) GetName() string {
+	return c.name
+}
+
+func (c fakeCrawler) GetTicker() string {
+	return "BTCUSD"
+}
+
+func (c fakeCrawler) GetMarket() types.Market {
+	return types.Market{Base: "BTC", Quote: "USD"}
+}
+
+func TestCrawl(t *testing.T) {
+
+	timeout := 50 * time.Millisecond
+	tests := []struct {
+		name    string
+		crawler fakeCrawler
+		err     string // Part of the expected error. Empty if the crawl must succeed
+	}{
+		{name: "answer", crawler: fakeCrawler{price: 9000, volume: 1}},
+		{name: "error", crawler: fakeCrawler{err: errors.New("bad gateway")}, err: "bad gateway"},
+		{name: "panic", crawler: fakeCrawler{panics: true}, err: "crawler failed: unexpected answer"},
+		{name: "timeout", crawler: fakeCrawler{delay: time.Second}, err: context.DeadlineExceeded.Error()},
+		// The crawlers that don´t listen to the context are not waited either
+		{name: "timeout ignored", crawler: fakeCrawler{delay: time.Second, ignoreCtx: true}, err: context.DeadlineExceeded.Error()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			start := time.Now()
+			data, err := crawl(ctx, types.GetDataJob{DataCrawler: test.crawler})
+			if elapsed := time.Since(start); elapsed > 10*timeout {
+				t.Errorf("the crawl took %v with a timeout of %v", elapsed, timeout)
+			}
+
+			if test.err == "" {
+				if err != nil {
+					t.Fatal(err)
+				}
+				if data.LastPrice != test.crawler.price {
+					t.Errorf("LastPrice = %v, want %v", data.LastPrice, test.crawler.price)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Fatalf("error %v, want %q", err, test.err)
+			}
+		})
+	}
+}
+
+// Creates a processor of BTC/USD over a chain in memory, with rounds of a second
+func newTestProcessor(t *testing.T, directory ...types.PriceEvidenceCrawler) Processor {
+	t.Helper()
+
+	chain, err := database.NewBlockChain("BTCUSD", "", database.WithMemoryStore())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { chain.Close() })
+
+	processor := NewMapReduceProcessor(directory, types.Market{Base: "BTC", Quote: "USD"}, chain, make(chan Publication, 1))
+	processor.Schedule = NewSchedule(time.Second)
+	processor.CrawlTimeout = 100 * time.Millisecond
+	return processor
+}
+
+func TestRunRound(t *testing.T) {
+
+	processor := newTestProcessor(t,
+		fakeCrawler{name: "a", price: 9000, volume: 1},
+		fakeCrawler{name: "b", price: 9010, volume: 1},
+		fakeCrawler{name: "c", price: 9020, volume: 2},
+		fakeCrawler{name: "failed", err: errors.New("bad gateway")},
+		fakeCrawler{name: "panic", panics: true},
+		fakeCrawler{name: "slow", price: 9000, volume: 10, delay: time.Second, ignoreCtx: true},
+	)
+
+	// The round is not aligned, so it has a whole interval to finish
+	round := time.Now()
+	start := time.Now()
+	processor.runRound(round)
+	if elapsed := time.Since(start); elapsed > processor.Schedule.Interval {
+		t.Errorf("the round took %v, more than its interval", elapsed)
+	}
+
+	var publication Publication
+	select {
+	case publication = <-processor.PublicationChan:
+	default:
+		t.Fatal("no block published")
+	}
+	block := publication.Block
+
+	// The half of the volume is between 9010 and 9020
+	if block.AveragePrice != 9015 || block.AverageVolume != 4 {
+		t.Errorf("price %v and volume %v, want 9015 and 4", block.AveragePrice, block.AverageVolume)
+	}
+	if block.Timestamp != uint64(round.Unix()) || block.Ticker != "BTCUSD" {
+		t.Errorf("unexpected timestamp %d or ticker %s", block.Timestamp, block.Ticker)
+	}
+	if len(block.Evidence) != 6 {
+		t.Fatalf("%d sources in the evidence, want all the 6", len(block.Evidence))
+	}
+	for _, source := range block.Evidence {
+		failed := source.CrawlerName == "failed" || source.CrawlerName == "panic" || source.CrawlerName == "slow"
+		if source.HasError != failed {
+			t.Errorf("source %s: HasError %v (%s)", source.CrawlerName, source.HasError, source.Error)
+		}
+		if err := source.VerifyHash(); err != nil {
+			t.Errorf("source %s: %v", source.CrawlerName, err)
+		}
+	}
+
+	stored, err := processor.Chain.GetBlockByHeight(block.Height)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stored.Hash != block.Hash {
+		t.Errorf("the stored block %s is not the published one %s", stored.Hash, block.Hash)
+	}
+
+	status := processor.LatestRound()
+	if !status.Published || status.Height != block.Height || status.Overrun || status.Error != "" {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestRunRoundWithoutQuorum(t *testing.T) {
+
+	processor := newTestProcessor(t,
+		fakeCrawler{name: "a", price: 9000, volume: 1},
+		fakeCrawler{name: "failed", err: errors.New("bad gateway")},
+	)
+	processor.runRound(time.Now())
+
+	select {
+	case publication := <-processor.PublicationChan:
+		t.Fatalf("block %d published without quorum", publication.Block.Height)
+	default:
+	}
+	status := processor.LatestRound()
+	if status.Published || status.Reason != cryptoindex.QuorumNotEnoughSources {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+func TestRunRoundOverrun(t *testing.T) {
+
+	processor := newTestProcessor(t,
+		fakeCrawler{name: "a", price: 9000, volume: 1},
+		fakeCrawler{name: "b", price: 9010, volume: 1},
+	)
+	// A round whose deadline has passed
+	processor.runRound(time.Now().Add(-2 * processor.Schedule.Interval))
+
+	select {
+	case publication := <-processor.PublicationChan:
+		t.Fatalf("block %d published from an overrun", publication.Block.Height)
+	default:
+	}
+	status := processor.LatestRound()
+	if status.Published || !status.Overrun || status.Reason != OverrunMemo {
+		t.Errorf("unexpected status %+v", status)
+	}
+}
+
+// The publication doesn´t wait for the listeners: with the channel full, the blocks are dropped and counted
+func TestPublishDropped(t *testing.T) {
+
+	processor := newTestProcessor(t)
+
+	for height := uint64(1); height <= 3; height++ {
+		processor.publish(types.FullSignedBlock{Height: height, Ticker: "BTCUSD"})
+	}
+
+	if dropped := processor.LatestRound().DroppedPublications; dropped != 2 {
+		t.Errorf("%d publications dropped, want 2", dropped)
+	}
+	publication := <-processor.PublicationChan
+	if publication.Block.Height != 1 {
+		t.Errorf("block %d published, want the first one", publication.Block.Height)
+	}
+	if publication.Attestation != nil {
+		t.Error("block attested without attester")
+	}
+
+	// The count is kept with the status of the next rounds
+	processor.setLatestRound(RoundStatus{Published: true})
+	processor.publish(types.FullSignedBlock{Height: 4, Ticker: "BTCUSD"})
+	if dropped := processor.LatestRound().DroppedPublications; dropped != 2 {
+		t.Errorf("%d publications dropped after a new round, want 2", dropped)
+	}
+}
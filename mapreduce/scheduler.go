@@ -0,0 +1,44 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package mapreduce
+
+import (
+	"time"
+)
+
+// Schedule places the rounds on fixed wall-clock boundaries, so every block matches a time bucket.
+// An interval of 5s starts the rounds at :00, :05, :10... and an interval of 1m at each whole minute
+type Schedule struct {
+	Interval time.Duration
+}
+
+// NewSchedule creates a schedule with rounds every interval
+func NewSchedule(interval time.Duration) Schedule {
+	return Schedule{Interval: interval}
+}
+
+// Bucket returns the start of the time bucket containing t
+func (s Schedule) Bucket(t time.Time) time.Time {
+	return t.Truncate(s.Interval)
+}
+
+// Next returns the first boundary after t
+func (s Schedule) Next(t time.Time) time.Time {
+	return s.Bucket(t).Add(s.Interval)
+}
+
+// Deadline returns the time when the round started at the boundary must be finished, this is, the next boundary
+func (s Schedule) Deadline(round time.Time) time.Time {
+	return round.Add(s.Interval)
+}
+
+// Missed returns how many boundaries after the round were already passed at t. A round finished just at its
+// deadline missed none
+func (s Schedule) Missed(round time.Time, t time.Time) int {
+	if !t.After(s.Deadline(round)) {
+		return 0
+	}
+
+	return int(s.Bucket(t).Sub(round) / s.Interval)
+}
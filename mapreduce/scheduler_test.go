@@ -0,0 +1,67 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package mapreduce
+
+import (
+	"testing"
+	"time"
+)
+
+func TestScheduleBoundaries(t *testing.T) {
+
+	schedule := NewSchedule(5 * time.Second)
+	boundary := time.Date(2020, 3, 1, 12, 0, 5, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		t      time.Time
+		bucket time.Time
+		next   time.Time
+	}{
+		{name: "on a boundary", t: boundary, bucket: boundary, next: boundary.Add(5 * time.Second)},
+		{name: "inside a bucket", t: boundary.Add(2300 * time.Millisecond), bucket: boundary, next: boundary.Add(5 * time.Second)},
+		{name: "just before a boundary", t: boundary.Add(-time.Nanosecond), bucket: boundary.Add(-5 * time.Second), next: boundary},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if bucket := schedule.Bucket(test.t); !bucket.Equal(test.bucket) {
+				t.Errorf("Bucket = %v, want %v", bucket, test.bucket)
+			}
+			if next := schedule.Next(test.t); !next.Equal(test.next) {
+				t.Errorf("Next = %v, want %v", next, test.next)
+			}
+		})
+	}
+
+	if deadline := schedule.Deadline(boundary); !deadline.Equal(boundary.Add(5 * time.Second)) {
+		t.Errorf("Deadline = %v, want the next boundary", deadline)
+	}
+}
+
+func TestScheduleMissed(t *testing.T) {
+
+	schedule := NewSchedule(5 * time.Second)
+	round := time.Date(2020, 3, 1, 12, 0, 5, 0, time.UTC)
+	deadline := schedule.Deadline(round)
+
+	tests := []struct {
+		name   string
+		t      time.Time
+		missed int
+	}{
+		{name: "inside the round", t: round.Add(time.Second), missed: 0},
+		{name: "at the deadline", t: deadline, missed: 0},
+		{name: "just after the deadline", t: deadline.Add(time.Nanosecond), missed: 1},
+		{name: "inside the next round", t: deadline.Add(4 * time.Second), missed: 1},
+		{name: "two rounds later", t: deadline.Add(5 * time.Second), missed: 2},
+		{name: "several rounds later", t: deadline.Add(17 * time.Second), missed: 4},
+	}
+
+	for _, test := range tests {
+		if missed := schedule.Missed(round, test.t); missed != test.missed {
+			t.Errorf("%s: Missed = %d, want %d", test.name, missed, test.missed)
+		}
+	}
+}
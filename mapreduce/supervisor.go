@@ -0,0 +1,177 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package mapreduce
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/aquarelle-tech/darkmatter/attestation"
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/types"
+)
+
+// ErrUnknownMarket is returned by the queries about a market without pipeline
+var ErrUnknownMarket = errors.New("there is no pipeline for the market")
+
+// PipelineConfig is the configuration of the pipeline of a market
+type PipelineConfig struct {
+	Market    types.Market
+	Directory []types.PriceEvidenceCrawler
+	Interval  time.Duration // Cadence of the rounds, aligned to the wall clock. DEFAULT_ROUND_INTERVAL if not set
+}
+
+// Supervisor runs an independent map-reduce pipeline for each market, each one with its own crawlers, cadence and
+// chain. All the blocks are published in the same channel
+type Supervisor struct {
+	PublicationChan chan Publication
+	DataDirectory   string
+	MaxStaleness    time.Duration         // Maximum distance between a block and the time of a price query. 0 means no limit
+	ChainOptions    []database.Option     // Storage of the chains. A Badger database for each market by default
+	Attester        *attestation.Attester // Signs the attestations of the blocks. Without attester, they are disabled
+
+	mutex      sync.RWMutex
+	processors map[string]Processor // Indexed by ticker
+}
+
+// NewSupervisor creates a supervisor storing the chains of the markets under the data directory
+func NewSupervisor(dataDirectory string, publicationChan chan Publication) *Supervisor {
+	return &Supervisor{
+		PublicationChan: publicationChan,
+		DataDirectory:   dataDirectory,
+		processors:      make(map[string]Processor),
+	}
+}
+
+// AddPipeline creates the processor and the chain for a market. The pipeline starts with Initialize
+func (s *Supervisor) AddPipeline(config PipelineConfig) (Processor, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	ticker := config.Market.Ticker()
+	if _, exists := s.processors[ticker]; exists {
+		return Processor{}, fmt.Errorf("there is already a pipeline for %s", config.Market)
+	}
+	if len(config.Directory) == 0 {
+		return Processor{}, fmt.Errorf("there are no crawlers for %s", config.Market)
+	}
+
+	chain, err := database.NewBlockChain(ticker, filepath.Join(s.DataDirectory, ticker), s.ChainOptions...)
+	if err != nil {
+		return Processor{}, err
+	}
+
+	processor := NewMapReduceProcessor(config.Directory, config.Market, chain, s.PublicationChan)
+	processor.Attester = s.Attester
+	if config.Interval > 0 {
+		processor.Schedule = NewSchedule(config.Interval)
+	}
+	s.processors[ticker] = processor
+
+	return processor, nil
+}
+
+// Processor returns the pipeline of a market by its ticker (BTCUSD)
+func (s *Supervisor) Processor(ticker string) (Processor, bool) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	processor, ok := s.processors[ticker]
+	return processor, ok
+}
+
+// PriceAt returns the price of a market (by ticker) at a time, from the latest block at or before it, or from the
+// nearest block. The answer is ErrStalePrice when the block is farther than MaxStaleness
+func (s *Supervisor) PriceAt(ticker string, t time.Time, nearest bool) (database.PricePoint, error) {
+
+	processor, ok := s.Processor(ticker)
+	if !ok {
+		return database.PricePoint{}, ErrUnknownMarket
+	}
+
+	return processor.Chain.PriceAt(t, nearest, s.MaxStaleness)
+}
+
+// RoundStatus returns the outcome of the latest round of a market (by ticker). It is empty until the first round
+// finishes
+func (s *Supervisor) RoundStatus(ticker string) (RoundStatus, error) {
+
+	processor, ok := s.Processor(ticker)
+	if !ok {
+		return RoundStatus{}, ErrUnknownMarket
+	}
+
+	return processor.LatestRound(), nil
+}
+
+// EvidenceProof returns the proof of inclusion of a source in the block of a market (by ticker) at a height
+func (s *Supervisor) EvidenceProof(ticker string, height uint64, source string) (database.EvidenceProof, error) {
+
+	processor, ok := s.Processor(ticker)
+	if !ok {
+		return database.EvidenceProof{}, ErrUnknownMarket
+	}
+
+	return processor.Chain.EvidenceProof(height, source)
+}
+
+// Attestation returns the attestation of the block of a market (by ticker) at a height
+func (s *Supervisor) Attestation(ticker string, height uint64) (attestation.Attestation, error) {
+
+	if s.Attester == nil {
+		return attestation.Attestation{}, attestation.ErrAttestationsDisabled
+	}
+	processor, ok := s.Processor(ticker)
+	if !ok {
+		return attestation.Attestation{}, ErrUnknownMarket
+	}
+	block, err := processor.Chain.GetBlockByHeight(height)
+	if err != nil {
+		return attestation.Attestation{}, err
+	}
+
+	return s.Attester.Attest(*block)
+}
+
+// Tickers returns the tickers of all the markets with a pipeline
+func (s *Supervisor) Tickers() []string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	var tickers []string
+	for ticker := range s.processors {
+		tickers = append(tickers, ticker)
+	}
+	sort.Strings(tickers)
+
+	return tickers
+}
+
+// Initialize launches all the pipelines. Each one runs in its own loop, so a slow market doesn´t stop the others
+func (s *Supervisor) Initialize() {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, processor := range s.processors {
+		processor.Initialize()
+	}
+}
+
+// Close stops all the pipelines and releases their chains
+func (s *Supervisor) Close() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for ticker, processor := range s.processors {
+		processor.Stop()
+		if err := processor.Chain.Close(); err != nil {
+			log.Printf("Error closing the chain of %s: %v", ticker, err)
+		}
+	}
+}
@@ -4,33 +4,76 @@
 package service
 
 import (
+	"encoding/json"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"sync"
+	"time"
 
 	"path/filepath"
 
+	"github.com/aquarelle-tech/darkmatter/attestation"
+	"github.com/aquarelle-tech/darkmatter/database"
+	"github.com/aquarelle-tech/darkmatter/mapreduce"
 	"github.com/aquarelle-tech/darkmatter/types"
 	"github.com/gorilla/websocket"
 )
 
-var clients = make(map[*websocket.Conn]bool)           // connected clients
-var broadcast = make(chan types.LiteIndexValueMessage) // Broadcast channel
+var clients = make(map[*websocket.Conn]bool) // connected clients
+var clientsMutex sync.Mutex                  // Guards the clients, registered and removed from the handlers
+var broadcast = make(chan PriceMessage)      // Broadcast channel
 var upgrader = websocket.Upgrader{}
 
+// PriceMessage is the message sent to the listeners for each new block, with its attestation when they are enabled
+type PriceMessage struct {
+	types.LiteIndexValueMessage
+	Attestation *attestation.Attestation `json:"attestation,omitempty"`
+}
+
+// PriceLookup finds the price of a market at a given time
+type PriceLookup interface {
+	PriceAt(ticker string, t time.Time, nearest bool) (database.PricePoint, error)
+}
+
+// StatusLookup returns the outcome of the latest round of the pipeline of a market
+type StatusLookup interface {
+	RoundStatus(ticker string) (mapreduce.RoundStatus, error)
+}
+
+// ProofLookup builds the proofs of inclusion of the sources in the blocks of a market
+type ProofLookup interface {
+	EvidenceProof(ticker string, height uint64, source string) (database.EvidenceProof, error)
+}
+
+// AttestationLookup signs the prices of the stored blocks for the smart contracts. It returns
+// attestation.ErrAttestationsDisabled when the node has no attestation key
+type AttestationLookup interface {
+	Attestation(ticker string, height uint64) (attestation.Attestation, error)
+}
+
 type OracleServer struct {
 	// Channel to se
-	Published chan types.FullSignedBlock
-	Broadcast chan types.LiteIndexValueMessage
-	Clients   map[*websocket.Conn]bool
+	Published    chan mapreduce.Publication
+	Broadcast    chan PriceMessage
+	Clients      map[*websocket.Conn]bool
+	Prices       PriceLookup
+	Status       StatusLookup
+	Proofs       ProofLookup
+	Attestations AttestationLookup
 }
 
-func NewOracleServer(published chan types.FullSignedBlock) OracleServer {
+func NewOracleServer(published chan mapreduce.Publication, prices PriceLookup, status StatusLookup, proofs ProofLookup, attestations AttestationLookup) OracleServer {
 	return OracleServer{
-		Published: published,
-		Broadcast: broadcast,
-		Clients:   clients,
+		Published:    published,
+		Broadcast:    broadcast,
+		Clients:      clients,
+		Prices:       prices,
+		Status:       status,
+		Proofs:       proofs,
+		Attestations: attestations,
 	}
 }
 
@@ -41,6 +84,7 @@ func (o OracleServer) broadcastMessages() {
 		msg := <-o.Broadcast
 
 		// Send it out to every client that is currently connected
+		clientsMutex.Lock()
 		for client := range o.Clients {
 			err := client.WriteJSON(msg)
 
@@ -51,6 +95,31 @@ func (o OracleServer) broadcastMessages() {
 				delete(o.Clients, client)
 			}
 		}
+		clientsMutex.Unlock()
+	}
+}
+
+// Read the blocks published by the data processors and send them to the broadcast queue. There is a single reader
+// for all the listeners, so every block is sent once and in the order of publication
+func (o OracleServer) publishBlocks() {
+	for {
+		publication := <-o.Published // Get a message from the public queue
+		msg := publication.Block
+		log.Printf("MESSAGE: Volume=%f, Price=%f", msg.AverageVolume, msg.AveragePrice)
+
+		liteMessage := PriceMessage{LiteIndexValueMessage: types.LiteIndexValueMessage{
+			Hash:          msg.Hash,
+			Height:        msg.Height,
+			PriceIndex:    msg.AveragePrice,
+			Quoted:        msg.Ticker,
+			NodeAddress:   msg.Address,
+			Signature:     msg.Signature,
+			Timestamp:     msg.Timestamp,
+			Confirmations: len(msg.Evidence),
+		}, Attestation: publication.Attestation} // Sent next to the block, so the listeners can forward it to a contract
+
+		// Send the newly received message to the broadcast channel
+		o.Broadcast <- liteMessage
 	}
 }
 
@@ -79,25 +148,165 @@ func (o OracleServer) handlePriceListeners(w http.ResponseWriter, r *http.Reques
 	defer ws.Close()
 
 	// Register a new listener
+	clientsMutex.Lock()
 	o.Clients[ws] = true
+	clientsMutex.Unlock()
 
-	// An infinite loop to get the published messages from the data processors ad send to the broadcast queue
+	// The listeners only receive. Read until the client leaves, so it is removed from the list
 	for {
-		msg := <-o.Published // Get a message from the public queue
-		log.Printf("MESSAGE: Volume=%f, HighPrice=%f", msg.AverageVolume, msg.AveragePrice)
-
-		liteMessage := types.LiteIndexValueMessage{
-			Hash:          msg.Hash,
-			Height:        msg.Height,
-			PriceIndex:    msg.AveragePrice,
-			Quoted:        msg.Ticker,
-			NodeAddress:   msg.Address,
-			Timestamp:     msg.Timestamp,
-			Confirmations: len(msg.Evidence),
+		if _, _, err := ws.ReadMessage(); err != nil {
+			break
 		}
+	}
+	clientsMutex.Lock()
+	delete(o.Clients, ws)
+	clientsMutex.Unlock()
+}
 
-		// Send the newly received message to the broadcast channel
-		o.Broadcast <- liteMessage
+// Read a time from a query, as Unix seconds or RFC 3339. An empty value is the current time
+func parseQueryTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return time.Unix(seconds, 0), nil
+	}
+
+	return time.Parse(time.RFC3339, value)
+}
+
+// Send a value as json
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		log.Printf("Error writing an answer: %v", err)
+	}
+}
+
+// Answer the price of a market at a given time, like /price-at?market=BTC/USD&time=1573257996&nearest=true.
+// A stale price is sent with the status 409, so it is never taken as a valid answer by mistake
+func (o OracleServer) handlePriceAt(w http.ResponseWriter, r *http.Request) {
+
+	setupResponse(&w, r)
+	if (*r).Method == "OPTIONS" {
+		return
+	}
+
+	query := r.URL.Query()
+	market, err := types.ParseMarket(query.Get("market"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	t, err := parseQueryTime(query.Get("time"))
+	if err != nil {
+		http.Error(w, "Invalid time, expected Unix seconds or RFC 3339", http.StatusBadRequest)
+		return
+	}
+	nearest, _ := strconv.ParseBool(query.Get("nearest"))
+
+	point, err := o.Prices.PriceAt(market.Ticker(), t, nearest)
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, point)
+	case database.ErrStalePrice:
+		writeJSON(w, http.StatusConflict, point)
+	case database.ErrNoPrice, mapreduce.ErrUnknownMarket:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Printf("Error looking for the price of %s: %v", market, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// Answer the outcome of the latest round of a market, like /status?market=BTC/USD
+func (o OracleServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+
+	setupResponse(&w, r)
+	if (*r).Method == "OPTIONS" {
+		return
+	}
+
+	market, err := types.ParseMarket(r.URL.Query().Get("market"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	status, err := o.Status.RoundStatus(market.Ticker())
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, status)
+	case mapreduce.ErrUnknownMarket:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Printf("Error reading the status of %s: %v", market, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// Answer the proof of inclusion of a source in a block, like /proof?market=BTC/USD&height=16&source=Kraken REST API
+func (o OracleServer) handleEvidenceProof(w http.ResponseWriter, r *http.Request) {
+
+	setupResponse(&w, r)
+	if (*r).Method == "OPTIONS" {
+		return
+	}
+
+	query := r.URL.Query()
+	market, err := types.ParseMarket(query.Get("market"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	height, err := strconv.ParseUint(query.Get("height"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid height", http.StatusBadRequest)
+		return
+	}
+
+	proof, err := o.Proofs.EvidenceProof(market.Ticker(), height, query.Get("source"))
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, proof)
+	case database.ErrNotFound, database.ErrNoMerkleRoot, mapreduce.ErrUnknownMarket:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Printf("Error building a proof for %s: %v", market, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+	}
+}
+
+// Answer the attestation of the price of a block, like /attestation?market=BTC/USD&height=16
+func (o OracleServer) handleAttestation(w http.ResponseWriter, r *http.Request) {
+
+	setupResponse(&w, r)
+	if (*r).Method == "OPTIONS" {
+		return
+	}
+
+	query := r.URL.Query()
+	market, err := types.ParseMarket(query.Get("market"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	height, err := strconv.ParseUint(query.Get("height"), 10, 64)
+	if err != nil {
+		http.Error(w, "Invalid height", http.StatusBadRequest)
+		return
+	}
+
+	signed, err := o.Attestations.Attestation(market.Ticker(), height)
+	switch err {
+	case nil:
+		writeJSON(w, http.StatusOK, signed)
+	case database.ErrNotFound, attestation.ErrAttestationsDisabled, attestation.ErrNoPrice, mapreduce.ErrUnknownMarket:
+		http.Error(w, err.Error(), http.StatusNotFound)
+	default:
+		log.Printf("Error attesting the block %d of %s: %v", height, market, err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
 	}
 }
 
@@ -136,6 +345,19 @@ func (o OracleServer) Initialize() {
 	// The main route to get the websocket path
 	http.HandleFunc("/price", o.handlePriceListeners)
 
+	// Price of a market at a given time, from the stored blocks
+	http.HandleFunc("/price-at", o.handlePriceAt)
+
+	// Outcome of the latest round of a market
+	http.HandleFunc("/status", o.handleStatus)
+
+	// Proof of inclusion of a source in a block
+	http.HandleFunc("/proof", o.handleEvidenceProof)
+
+	// EIP-712 attestation of the price of a block, for the smart contracts
+	http.HandleFunc("/attestation", o.handleAttestation)
+
 	// Launch subrouting to handle messages
 	go o.broadcastMessages()
+	go o.publishBlocks()
 }
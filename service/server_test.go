@@ -0,0 +1,77 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/aquarelle-tech/darkmatter/cryptoindex"
+	"github.com/aquarelle-tech/darkmatter/mapreduce"
+)
+
+// Answers the status of a round for each ticker, or the error set for it
+type fakeStatus struct {
+	rounds map[string]mapreduce.RoundStatus
+	err    error
+}
+
+func (f fakeStatus) RoundStatus(ticker string) (mapreduce.RoundStatus, error) {
+	if f.err != nil {
+		return mapreduce.RoundStatus{}, f.err
+	}
+	status, ok := f.rounds[ticker]
+	if !ok {
+		return mapreduce.RoundStatus{}, mapreduce.ErrUnknownMarket
+	}
+	return status, nil
+}
+
+func TestHandleStatus(t *testing.T) {
+
+	round := time.Date(2020, 3, 1, 0, 0, 0, 0, time.UTC)
+	lookup := fakeStatus{rounds: map[string]mapreduce.RoundStatus{
+		"BTCUSD": {Round: round, Reason: cryptoindex.QuorumNotEnoughVenues, Error: "quorum not met"},
+	}}
+
+	tests := []struct {
+		name   string
+		query  string
+		status StatusLookup
+		code   int
+	}{
+		{name: "market", query: "market=BTC/USD", status: lookup, code: http.StatusOK},
+		{name: "market with a dash", query: "market=btc-usd", status: lookup, code: http.StatusOK},
+		{name: "unknown market", query: "market=ETH/USD", status: lookup, code: http.StatusNotFound},
+		{name: "invalid market", query: "market=BTC", status: lookup, code: http.StatusBadRequest},
+		{name: "lookup error", query: "market=BTC/USD", status: fakeStatus{err: errors.New("failed")}, code: http.StatusInternalServerError},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := OracleServer{Status: test.status}
+			recorder := httptest.NewRecorder()
+			server.handleStatus(recorder, httptest.NewRequest(http.MethodGet, "/status?"+test.query, nil))
+
+			if recorder.Code != test.code {
+				t.Fatalf("status %d, want %d: %s", recorder.Code, test.code, recorder.Body.String())
+			}
+			if test.code != http.StatusOK {
+				return
+			}
+
+			var status mapreduce.RoundStatus
+			if err := json.Unmarshal(recorder.Body.Bytes(), &status); err != nil {
+				t.Fatal(err)
+			}
+			if !status.Round.Equal(round) || status.Published || status.Reason != cryptoindex.QuorumNotEnoughVenues {
+				t.Errorf("unexpected status %+v", status)
+			}
+		})
+	}
+}
@@ -0,0 +1,243 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"time"
+)
+
+// Versions of the schemes used to hash the blocks and their sources. The version is stored with each hash, so
+// the old blocks can be verified after a new scheme is introduced
+const (
+	// LegacyHashVersion is the double sha256 of the json of the content, salted with the ServiceHash. The blocks
+	// hashes are prefixed with BlockHashPrefix and the seconds of their timestamp
+	LegacyHashVersion = 0
+	// CanonicalHashVersion is the sha256 of the canonical encoding of the content: a fixed field order, with
+	// fixed-point prices. The block hashes cover the Merkle root of the evidence instead of the evidence itself.
+	// See docs/hashing.md
+	CanonicalHashVersion = 1
+	// KeyRotationHashVersion is the CanonicalHashVersion with the NextAddress of the blocks, which announces the
+	// rotation of the key of the node. The encoding of the sources doesn´t change
+	KeyRotationHashVersion = 2
+
+	// CurrentHashVersion is the scheme used for the new hashes
+	CurrentHashVersion = KeyRotationHashVersion
+)
+
+// Number of decimals of the fixed-point prices and volumes in the canonical encoding
+const FixedPointDecimals = 8
+
+// Tags of the kinds of content in the canonical encoding, after the version byte
+const (
+	canonicalBlockTag  = 'B'
+	canonicalResultTag = 'R'
+)
+
+// ErrHashMismatch is returned by VerifyHash when the hash doesn´t match the content
+var ErrHashMismatch = errors.New("the hash doesn´t match the content")
+
+// Scale of the fixed-point numbers, 10^FixedPointDecimals
+var fixedPointScale = math.Pow10(FixedPointDecimals)
+
+// Limits of a signed 128 bits integer
+var (
+	maxInt128 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 127), big.NewInt(1))
+	minInt128 = new(big.Int).Neg(new(big.Int).Lsh(big.NewInt(1), 127))
+	two128    = new(big.Int).Lsh(big.NewInt(1), 128)
+)
+
+// FixedPoint converts a number to the fixed-point integer of the canonical encoding: the number multiplied by
+// 10^FixedPointDecimals, rounded half away from zero
+func FixedPoint(value float64) (*big.Int, error) {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, fmt.Errorf("%v can´t be encoded as a fixed-point number", value)
+	}
+
+	scaled, _ := big.NewFloat(math.Round(value * fixedPointScale)).Int(nil)
+	if scaled.Cmp(maxInt128) > 0 || scaled.Cmp(minInt128) < 0 {
+		return nil, fmt.Errorf("%v is out of the range of the fixed-point numbers", value)
+	}
+
+	return scaled, nil
+}
+
+// canonicalEncoder writes the fields of the canonical encoding, keeping the first error
+type canonicalEncoder struct {
+	bytes []byte
+	err   error
+}
+
+func newCanonicalEncoder(version uint8, tag byte) *canonicalEncoder {
+	e := &canonicalEncoder{bytes: []byte{version, tag}}
+	if version < CanonicalHashVersion || version > CurrentHashVersion {
+		e.err = fmt.Errorf("the hash version %d has no canonical encoding", version)
+	}
+	return e
+}
+
+// Strings are written as their length (4 bytes, big-endian) and their UTF-8 bytes
+func (e *canonicalEncoder) string(value string) {
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(len(value)))
+	e.bytes = append(append(e.bytes, length[:]...), value...)
+}
+
+// Integers are written in 8 bytes, big-endian. The signed ones in two's complement
+func (e *canonicalEncoder) uint64(value uint64) {
+	var bytes [8]byte
+	binary.BigEndian.PutUint64(bytes[:], value)
+	e.bytes = append(e.bytes, bytes[:]...)
+}
+
+func (e *canonicalEncoder) int64(value int64) {
+	e.uint64(uint64(value))
+}
+
+// Booleans are written as one byte, 0 or 1
+func (e *canonicalEncoder) bool(value bool) {
+	if value {
+		e.bytes = append(e.bytes, 1)
+	} else {
+		e.bytes = append(e.bytes, 0)
+	}
+}
+
+// Prices and volumes are written as fixed-point numbers in 16 bytes, big-endian, in two's complement
+func (e *canonicalEncoder) fixed(value float64) {
+	scaled, err := FixedPoint(value)
+	if err != nil {
+		if e.err == nil {
+			e.err = err
+		}
+		scaled = new(big.Int)
+	}
+	if scaled.Sign() < 0 {
+		scaled = new(big.Int).Add(scaled, two128)
+	}
+
+	var bytes [16]byte
+	raw := scaled.Bytes()
+	copy(bytes[16-len(raw):], raw)
+	e.bytes = append(e.bytes, bytes[:]...)
+}
+
+// CanonicalEncoding returns the bytes hashed for a source with its HashVersion
+func (result Result) CanonicalEncoding() ([]byte, error) {
+	e := newCanonicalEncoder(result.HashVersion, canonicalResultTag)
+	e.string(result.CrawlerName)
+	e.string(result.Ticker)
+	e.int64(result.Timestamp)
+	e.bool(result.HasError)
+	e.string(result.Error)
+	e.bool(result.Excluded)
+	e.string(result.ExclusionReason)
+	e.fixed(result.ConversionRate)
+	e.fixed(result.Data.LastPrice)
+	e.fixed(result.Data.Volume)
+	e.fixed(result.Data.QuoteVolume)
+	e.fixed(result.Data.HighPrice)
+	e.fixed(result.Data.OpenPrice)
+	e.fixed(result.Data.BidPrice)
+	e.fixed(result.Data.AskPrice)
+	e.int64(result.Data.Timestamp)
+	e.string(result.Data.DataURL)
+	e.string(result.Data.QuoteCurrency)
+
+	return e.bytes, e.err
+}
+
+// CanonicalEncoding returns the bytes hashed for a block with its HashVersion. The evidence is covered by the
+// Merkle root
+func (block FullSignedBlock) CanonicalEncoding() ([]byte, error) {
+	e := newCanonicalEncoder(block.HashVersion, canonicalBlockTag)
+	e.uint64(block.Height)
+	e.uint64(block.Timestamp)
+	e.string(block.Ticker)
+	e.fixed(block.AveragePrice)
+	e.fixed(block.AverageVolume)
+	e.string(block.PreviousHash)
+	e.string(block.MerkleRoot)
+	e.string(block.Address)
+	e.string(block.PreviousAddress)
+	e.string(block.Memo)
+	if block.HashVersion >= KeyRotationHashVersion {
+		e.string(block.NextAddress)
+	}
+
+	return e.bytes, e.err
+}
+
+// Hash of a canonical encoding
+func canonicalHash(encoding []byte, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	digest := sha256.Sum256(encoding)
+	return hex.EncodeToString(digest[:]), nil
+}
+
+// ComputeHash returns the hash of the source with the scheme of its HashVersion, without changing it
+func (result Result) ComputeHash() (string, error) {
+	switch result.HashVersion {
+	case LegacyHashVersion:
+		result.Hash = ""
+		return calculateHash(&result)
+	case CanonicalHashVersion, KeyRotationHashVersion:
+		return canonicalHash(result.CanonicalEncoding())
+	}
+
+	return "", fmt.Errorf("unknown hash version %d", result.HashVersion)
+}
+
+// ComputeHash returns the hash of the block with the scheme of its HashVersion, without changing it
+func (block FullSignedBlock) ComputeHash() (string, error) {
+	switch block.HashVersion {
+	case LegacyHashVersion:
+		block.Hash = ""
+		hash, err := calculateHash(&block)
+		// The hashes for the block has attached a prefix and the the number of seconds taken from the timestamp
+		seconds := time.Unix(int64(block.Timestamp), 0).Second()
+		return fmt.Sprintf("%s%02d%s", BlockHashPrefix, seconds, hash), err
+	case CanonicalHashVersion, KeyRotationHashVersion:
+		return canonicalHash(block.CanonicalEncoding())
+	}
+
+	return "", fmt.Errorf("unknown hash version %d", block.HashVersion)
+}
+
+// VerifyHash checks the hash of a source with the scheme of its HashVersion
+func (result Result) VerifyHash() error {
+	hash, err := result.ComputeHash()
+	if err != nil {
+		return err
+	}
+	if hash != result.Hash {
+		return ErrHashMismatch
+	}
+	return nil
+}
+
+// VerifyHash checks the hash of a block with the scheme of its HashVersion. With the canonical scheme, when the
+// block has its evidence, the Merkle root must match the hashes of the sources too. The hashes of the sources are
+// not checked: use VerifyHash on each of them
+func (block FullSignedBlock) VerifyHash() error {
+	hash, err := block.ComputeHash()
+	if err != nil {
+		return err
+	}
+	if hash != block.Hash {
+		return ErrHashMismatch
+	}
+	if block.HashVersion >= CanonicalHashVersion && len(block.Evidence) > 0 && EvidenceRoot(block.Evidence) != block.MerkleRoot {
+		return ErrHashMismatch
+	}
+	return nil
+}
@@ -0,0 +1,193 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package types
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"testing"
+)
+
+// Published test vectors of the canonical hash scheme
+const hashVectorsFile = "../docs/hash-vectors.json"
+
+// Encodes the vectors as they are published, with -hash-vectors
+func encodeVectors(t *testing.T, vectors interface{}) []byte {
+	t.Helper()
+
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(vectors); err != nil {
+		t.Fatal(err)
+	}
+	return buffer.Bytes()
+}
+
+func TestCanonicalHashVectors(t *testing.T) {
+
+	vectors, err := CanonicalHashVectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	published, err := ioutil.ReadFile(hashVectorsFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	published = bytes.ReplaceAll(published, []byte("\r\n"), []byte("\n"))
+
+	if !bytes.Equal(encodeVectors(t, vectors), published) {
+		t.Fatalf("the vectors don´t match %s, run the node with -hash-vectors to publish them again", hashVectorsFile)
+	}
+}
+
+func TestHashVectorsVerify(t *testing.T) {
+
+	vectors, err := CanonicalHashVectors()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(vectors.Versions) != CurrentHashVersion {
+		t.Fatalf("%d versions of the vectors, want %d", len(vectors.Versions), CurrentHashVersion)
+	}
+	for _, versioned := range vectors.Versions {
+		for _, vector := range versioned.Results {
+			if int(vector.Result.HashVersion) != versioned.HashVersion {
+				t.Errorf("version %d: source %s has the version %d", versioned.HashVersion, vector.Result.CrawlerName, vector.Result.HashVersion)
+			}
+			if err := vector.Result.VerifyHash(); err != nil {
+				t.Errorf("version %d: source %s: %v", versioned.HashVersion, vector.Result.CrawlerName, err)
+			}
+		}
+		for _, vector := range versioned.Blocks {
+			block := vector.Block
+			if int(block.HashVersion) != versioned.HashVersion {
+				t.Errorf("version %d: block %d has the version %d", versioned.HashVersion, block.Height, block.HashVersion)
+			}
+			if err := block.VerifyHash(); err != nil {
+				t.Errorf("version %d: block %d: %v", versioned.HashVersion, block.Height, err)
+			}
+			if err := block.VerifySignature(); err != nil {
+				t.Errorf("version %d: block %d: %v", versioned.HashVersion, block.Height, err)
+			}
+
+			// A change in the content must break the hash, and a new hash must break the signature
+			block.AverageVolume++
+			if err := block.VerifyHash(); err != ErrHashMismatch {
+				t.Errorf("version %d: modified block %d verified with %v", versioned.HashVersion, block.Height, err)
+			}
+			if err := block.CreateHash(); err != nil {
+				t.Fatal(err)
+			}
+			if err := block.VerifySignature(); err == nil {
+				t.Errorf("version %d: block %d: signature verified for another hash", versioned.HashVersion, block.Height)
+			}
+		}
+	}
+}
+
+// A block of a chain created before the canonical hash scheme, with its hashes as they were calculated then
+const legacyBlock = `{"hash":"dd05ab94331ec57aecb4029206e635e8c108648fb7e6dff5101adeadc5ea32b1cae4","height":2,"timestamp":1583020805,"avgPrice":9601.17,"avgVolumen":4321.123,"ticker":"BTCUSD","previousHash":"dd04e3f1","address":"","previousAddress":"","memo":"","evidence":[{"name":"binance","data":{"quoteVolumen":41532118.55,"volume":4321.123,"highPrice":9650.5,"openPrice":9512.25,"timestamp":1583020800,"dataUrl":"https://api.binance.com/api/v3/ticker/24hr?symbol=BTCUSDT"},"hasError":false,"timestamp":1583020801,"ticker":"BTCUSDT","hash":"1d3996c675b033be491110fce6f5cbe410dcf50b31c877d491d7bf1082169821"},{"name":"liquid","data":{"quoteVolumen":0,"volume":0,"highPrice":0,"openPrice":0,"timestamp":0,"dataUrl":""},"hasError":true,"timestamp":1583020802,"ticker":"BTCUSD","hash":"745dae0d78135aa7225527247a5321d088be07614645787e88c2111be0c4ab67"}]}`
+
+func TestLegacyHashVerify(t *testing.T) {
+
+	var block FullSignedBlock
+	if err := json.Unmarshal([]byte(legacyBlock), &block); err != nil {
+		t.Fatal(err)
+	}
+
+	if block.HashVersion != LegacyHashVersion {
+		t.Fatalf("the block has the version %d, want %d", block.HashVersion, LegacyHashVersion)
+	}
+	if err := block.VerifyHash(); err != nil {
+		t.Errorf("block %d: %v", block.Height, err)
+	}
+	for _, source := range block.Evidence {
+		if err := source.VerifyHash(); err != nil {
+			t.Errorf("source %s: %v", source.CrawlerName, err)
+		}
+	}
+
+	// The legacy hash covers the evidence too
+	block.Evidence[0].Data.Volume++
+	if err := block.VerifyHash(); err != ErrHashMismatch {
+		t.Errorf("modified block %d verified with %v", block.Height, err)
+	}
+}
+
+func TestFixedPoint(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		value    float64
+		expected int64
+	}{
+		{name: "integer", value: 8750, expected: 875000000000},
+		{name: "eight decimals", value: 8750.12345678, expected: 875012345678},
+		{name: "rounded down", value: 0.000000004, expected: 0},
+		{name: "rounded up", value: 0.123456785, expected: 12345679},
+		{name: "half", value: 0.000000005, expected: 1},
+		{name: "negative half", value: -0.000000005, expected: -1},
+		// Half away from zero, not to the even integer
+		{name: "half to odd", value: 0.000000025, expected: 3},
+		{name: "negative half to odd", value: -0.000000025, expected: -3},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			scaled, err := FixedPoint(test.value)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !scaled.IsInt64() || scaled.Int64() != test.expected {
+				t.Errorf("FixedPoint(%v) = %v, expected %d", test.value, scaled, test.expected)
+			}
+		})
+	}
+
+	for _, value := range []float64{math.NaN(), math.Inf(1), math.Inf(-1), 1e40, -1e40} {
+		if _, err := FixedPoint(value); err == nil {
+			t.Errorf("FixedPoint(%v) didn´t fail", value)
+		}
+	}
+}
+
+func TestCanonicalEncoder(t *testing.T) {
+
+	e := newCanonicalEncoder(CanonicalHashVersion, canonicalResultTag)
+	e.string("BTC")
+	e.string("")
+	e.string("é")
+	e.uint64(1583020800)
+	e.int64(-1)
+	e.bool(true)
+	e.bool(false)
+	e.fixed(1.5)
+	e.fixed(-1)
+	if e.err != nil {
+		t.Fatal(e.err)
+	}
+
+	expected := "01" + "52" + // Version and tag
+		"00000003" + "425443" + // Length and bytes of the strings
+		"00000000" +
+		"00000002" + "c3a9" + // The length is in bytes, not in runes
+		"000000005e5afb00" +
+		"ffffffffffffffff" +
+		"01" + "00" +
+		"000000000000000000000000" + "08f0d180" + // 1.5 * 10^8
+		"ffffffffffffffffffffffff" + "fa0a1f00" // -1 * 10^8, in two's complement
+	if encoding := hex.EncodeToString(e.bytes); encoding != expected {
+		t.Errorf("encoding = %s, expected %s", encoding, expected)
+	}
+
+	for _, version := range []uint8{LegacyHashVersion, CurrentHashVersion + 1} {
+		if e := newCanonicalEncoder(version, canonicalBlockTag); e.err == nil {
+			t.Errorf("the hash version %d has a canonical encoding", version)
+		}
+	}
+}
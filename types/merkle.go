@@ -0,0 +1,128 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package types
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+)
+
+// Prefixes of the hashes of the Merkle tree, so a leaf can´t be taken as an inner node
+const (
+	merkleLeafPrefix = 0x00
+	merkleNodePrefix = 0x01
+)
+
+// MerkleStep is a step of an inclusion proof: the hash of the sibling node, and its side
+type MerkleStep struct {
+	Hash string `json:"hash"`
+	Left bool   `json:"left"` // The sibling is the left node
+}
+
+// MerkleProof proves that a leaf (the hash of a source) is included in a Merkle root
+type MerkleProof struct {
+	Leaf  string       `json:"leaf"`
+	Index int          `json:"index"`
+	Steps []MerkleStep `json:"steps"`
+	Root  string       `json:"root"`
+}
+
+// Hash of a leaf of the tree
+func merkleLeaf(leaf string) []byte {
+	hash := sha256.Sum256(append([]byte{merkleLeafPrefix}, leaf...))
+	return hash[:]
+}
+
+// Hash of an inner node of the tree
+func merkleNode(left []byte, right []byte) []byte {
+	content := append([]byte{merkleNodePrefix}, left...)
+	hash := sha256.Sum256(append(content, right...))
+	return hash[:]
+}
+
+// Returns the next level of a tree. When a level has an odd number of nodes, the last one goes up unchanged
+func merkleLevel(nodes [][]byte) [][]byte {
+	var level [][]byte
+	for i := 0; i < len(nodes); i += 2 {
+		if i+1 == len(nodes) {
+			level = append(level, nodes[i])
+		} else {
+			level = append(level, merkleNode(nodes[i], nodes[i+1]))
+		}
+	}
+	return level
+}
+
+// Hashes of the leaves of a tree
+func merkleLeaves(leaves []string) [][]byte {
+	nodes := make([][]byte, len(leaves))
+	for i, leaf := range leaves {
+		nodes[i] = merkleLeaf(leaf)
+	}
+	return nodes
+}
+
+// MerkleRoot returns the root of the Merkle tree of the leaves, in hexadecimal. Without leaves, the root is empty
+func MerkleRoot(leaves []string) string {
+	if len(leaves) == 0 {
+		return ""
+	}
+
+	nodes := merkleLeaves(leaves)
+	for len(nodes) > 1 {
+		nodes = merkleLevel(nodes)
+	}
+
+	return hex.EncodeToString(nodes[0])
+}
+
+// NewMerkleProof returns the proof of inclusion of the leaf at the position index
+func NewMerkleProof(leaves []string, index int) (MerkleProof, error) {
+	if index < 0 || index >= len(leaves) {
+		return MerkleProof{}, fmt.Errorf("there is no leaf %d in a tree of %d leaves", index, len(leaves))
+	}
+
+	proof := MerkleProof{Leaf: leaves[index], Index: index}
+	nodes := merkleLeaves(leaves)
+	position := index
+	for len(nodes) > 1 {
+		sibling := position ^ 1
+		if sibling < len(nodes) {
+			proof.Steps = append(proof.Steps, MerkleStep{Hash: hex.EncodeToString(nodes[sibling]), Left: sibling < position})
+		}
+		nodes = merkleLevel(nodes)
+		position /= 2
+	}
+	proof.Root = hex.EncodeToString(nodes[0])
+
+	return proof, nil
+}
+
+// Verify checks that the steps of the proof lead from the leaf to the root
+func (proof MerkleProof) Verify() bool {
+	node := merkleLeaf(proof.Leaf)
+	for _, step := range proof.Steps {
+		sibling, err := hex.DecodeString(step.Hash)
+		if err != nil {
+			return false
+		}
+		if step.Left {
+			node = merkleNode(sibling, node)
+		} else {
+			node = merkleNode(node, sibling)
+		}
+	}
+
+	return proof.Root != "" && hex.EncodeToString(node) == proof.Root
+}
+
+// EvidenceRoot returns the Merkle root of the hashes of the sources of a block
+func EvidenceRoot(evidence []Result) string {
+	leaves := make([]string, len(evidence))
+	for i, source := range evidence {
+		leaves[i] = source.Hash
+	}
+	return MerkleRoot(leaves)
+}
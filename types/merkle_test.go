@@ -0,0 +1,86 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package types
+
+import (
+	"fmt"
+	"testing"
+)
+
+// The hashes are sha256(0x00 || leaf) for the leaves and sha256(0x01 || left || right) for the inner nodes
+func TestMerkleRoot(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		leaves   []string
+		expected string
+	}{
+		{name: "no leaves", leaves: nil, expected: ""},
+		// sha256(0x00 || "a"), not sha256("a") = ca978112...
+		{name: "one leaf", leaves: []string{"a"}, expected: "022a6979e6dab7aa5ae4c3e5e45f7e977112a7e63593820dbec1ec738a24f93c"},
+		{name: "two leaves", leaves: []string{"a", "b"}, expected: "b137985ff484fb600db93107c77b0365c80d78f5b429ded0fd97361d077999eb"},
+		// The leaf c goes up unchanged, and it is hashed with the node of a and b
+		{name: "three leaves", leaves: []string{"a", "b", "c"}, expected: "36642e73c2540ab121e3a6bf9545b0a24982cd830eb13d3cd19de3ce6c021ec1"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if root := MerkleRoot(test.leaves); root != test.expected {
+				t.Errorf("MerkleRoot(%v) = %s, expected %s", test.leaves, root, test.expected)
+			}
+		})
+	}
+}
+
+func TestMerkleProof(t *testing.T) {
+
+	// The odd counts have nodes that go up unchanged, without a step in the proof
+	for count := 1; count <= 7; count++ {
+		leaves := make([]string, count)
+		for i := range leaves {
+			leaves[i] = fmt.Sprintf("leaf %d", i)
+		}
+		root := MerkleRoot(leaves)
+
+		for index := range leaves {
+			proof, err := NewMerkleProof(leaves, index)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if proof.Root != root || proof.Leaf != leaves[index] || !proof.Verify() {
+				t.Errorf("the proof of the leaf %d of %d doesn´t lead to the root: %+v", index, count, proof)
+			}
+
+			tampered := proof
+			tampered.Leaf = "another leaf"
+			if tampered.Verify() {
+				t.Errorf("the proof of the leaf %d of %d verifies another leaf", index, count)
+			}
+			if len(proof.Steps) > 0 {
+				tampered = proof
+				tampered.Steps = append([]MerkleStep(nil), proof.Steps...)
+				tampered.Steps[0].Left = !tampered.Steps[0].Left
+				if tampered.Verify() {
+					t.Errorf("the proof of the leaf %d of %d verifies with a sibling on the other side", index, count)
+				}
+			}
+		}
+
+		if _, err := NewMerkleProof(leaves, count); err == nil {
+			t.Errorf("NewMerkleProof() returned a proof of the leaf %d of %d", count, count)
+		}
+	}
+
+	// In a tree of 3 leaves, the leaf c goes up unchanged: its proof is the node of a and b
+	proof, err := NewMerkleProof([]string{"a", "b", "c"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := MerkleStep{Hash: "b137985ff484fb600db93107c77b0365c80d78f5b429ded0fd97361d077999eb", Left: true}
+	if len(proof.Steps) != 1 || proof.Steps[0] != expected {
+		t.Errorf("NewMerkleProof() = %+v, expected the step %+v", proof.Steps, expected)
+	}
+	if (MerkleProof{Leaf: "a"}).Verify() {
+		t.Error("a proof without root verifies")
+	}
+}
@@ -0,0 +1,86 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package types
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+	"errors"
+)
+
+// RotationMemo is the memo of the blocks that rotate the key of the node. They have no price
+const RotationMemo = "key-rotation"
+
+// ErrInvalidSignature is returned when the signature of a block doesn´t match its hash and its address
+var ErrInvalidSignature = errors.New("the signature of the block is not valid")
+
+// BlockSigner is the identity of a node. The address is the Ed25519 public key of the node, in hexadecimal
+type BlockSigner interface {
+	Address() string
+	SignHash(hash []byte) ([]byte, error)
+}
+
+// Sign sets the address of the signer in the block, creates the hash and signs it. The signature covers the bytes
+// of the canonical hash
+func (block *FullSignedBlock) Sign(signer BlockSigner) error {
+
+	block.Address = signer.Address()
+	if err := block.CreateHash(); err != nil {
+		return err
+	}
+
+	hash, err := hex.DecodeString(block.Hash)
+	if err != nil {
+		return err
+	}
+	signature, err := signer.SignHash(hash)
+	if err != nil {
+		return err
+	}
+	block.Signature = hex.EncodeToString(signature)
+
+	return nil
+}
+
+// IsKeyRotation returns true for the blocks that rotate the key of the node, which don´t have a price
+func (block FullSignedBlock) IsKeyRotation() bool {
+	return block.Memo == RotationMemo
+}
+
+// NextSigner returns the address that must sign the next block of the chain: the NextAddress when the block rotates
+// the key of the node, or its own address. It is empty for the unsigned blocks. The NextAddress of a block hashed
+// before KeyRotationHashVersion is ignored, as its hash and signature don´t cover it
+func (block FullSignedBlock) NextSigner() string {
+	if block.NextAddress != "" && block.HashVersion >= KeyRotationHashVersion {
+		return block.NextAddress
+	}
+	return block.Address
+}
+
+// VerifySignature checks the hash of the block, and that it was signed by the owner of the address of the block
+func (block FullSignedBlock) VerifySignature() error {
+
+	if err := block.VerifyHash(); err != nil {
+		return err
+	}
+
+	publicKey, err := hex.DecodeString(block.Address)
+	if err != nil || len(publicKey) != ed25519.PublicKeySize {
+		return ErrInvalidSignature
+	}
+	signature, err := hex.DecodeString(block.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return ErrInvalidSignature
+	}
+	hash, err := hex.DecodeString(block.Hash)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	if !ed25519.Verify(ed25519.PublicKey(publicKey), hash, signature) {
+		return ErrInvalidSignature
+	}
+
+	return nil
+}
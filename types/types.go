@@ -2,10 +2,11 @@
 package types
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/json"
 	"fmt"
-	"time"
+	"strings"
 
 	"log"
 )
@@ -18,14 +19,57 @@ const (
 	BlockHashPrefix = "dd"
 )
 
+// Reasons to exclude a result from the calculation of the index. They are stored with the evidence of each block
+const (
+	// ExclusionOutlierMAD marks a price too far from the median, measured in median absolute deviations
+	ExclusionOutlierMAD = "outlier-mad"
+	// ExclusionOutlierBand marks a price outside the percentage band around the median
+	ExclusionOutlierBand = "outlier-band"
+	// ExclusionQuoteMismatch marks a price quoted in other currency, without a rate to convert it
+	ExclusionQuoteMismatch = "quote-mismatch"
+)
+
 // KVStore defines a KV pair storage manager definition
 type KVStore interface {
 	StoreValue(key string, value []byte) error
 	GetValue(key string) ([]byte, error)
 	StoreBlock(block FullSignedBlock) error
+	// CommitBlock stores a block, its indexes and the block as the value of latestKey in a single transaction
+	CommitBlock(block FullSignedBlock, latestKey string) error
 	GetBlock(hash string) (*FullSignedBlock, error)
 	FindBlockByTimestamp(timestamp uint64) (*FullSignedBlock, error)
 	FindBlockByHeight(Height uint64) (*FullSignedBlock, error)
+	// Range queries over the indexes, both limits included. A limit of 0 returns all the blocks in the range
+	BlocksByHeight(from uint64, to uint64, limit int, reverse bool) ([]FullSignedBlock, error)
+	BlocksByTimestamp(from uint64, to uint64, limit int, reverse bool) ([]FullSignedBlock, error)
+	Close() error
+}
+
+// Market is a pair of assets, where the price of the base asset is quoted in the quote asset
+type Market struct {
+	Base  string `json:"base"`
+	Quote string `json:"quote"`
+}
+
+// ParseMarket returns the market for a text like BTC/USD or BTC-USD
+func ParseMarket(text string) (Market, error) {
+	parts := strings.FieldsFunc(strings.ToUpper(text), func(r rune) bool {
+		return r == '/' || r == '-' || r == '_'
+	})
+	if len(parts) != 2 {
+		return Market{}, fmt.Errorf("invalid market %q, expected BASE/QUOTE", text)
+	}
+
+	return Market{Base: parts[0], Quote: parts[1]}, nil
+}
+
+// Ticker returns the name of the market used in the blocks, like BTCUSD
+func (m Market) Ticker() string {
+	return m.Base + m.Quote
+}
+
+func (m Market) String() string {
+	return m.Base + "/" + m.Quote
 }
 
 // QuotePriceInfo is the model used to get the data
@@ -35,16 +79,19 @@ type QuotePriceInfo struct {
 	// PriceChangePercent float32 `json:"priceChangePercent"`
 	// LastQty            float32 `json:"LastQty"`
 	// // LastPrice          float32 `json:"lastPrice"`
-	// BidPrice           float32 `json:"bidPrice"`
-	// AskPrice           float32 `json:"askPrice"`
 	// BidQty             float32 `json:"bidQty"`
 	// AskQty             float32 `json:"askQty"`
 	QuoteVolume float64 `json:"quoteVolumen"`
 	Volume      float64 `json:"volume"`
 	HighPrice   float64 `json:"highPrice"`
+	LastPrice   float64 `json:"lastPrice,omitempty"`
 	OpenPrice   float64 `json:"openPrice"`
+	BidPrice    float64 `json:"bidPrice,omitempty"`
+	AskPrice    float64 `json:"askPrice,omitempty"`
 	Timestamp   int64   `json:"timestamp"`
 	DataURL     string  `json:"dataUrl"`
+	// QuoteCurrency is the currency of the prices, as returned by the venue (USD, USDT, KRW...)
+	QuoteCurrency string `json:"quoteCurrency,omitempty"`
 	// LowPrice           float64 `json:"lowPrice"`
 	// OpenTime           int64  `json:"openTime"`
 	// CloseTime          int64  `json:"closeTime"`
@@ -67,41 +114,41 @@ type LiteIndexValueMessage struct {
 	PriceIndex    float64 `json:"priceIndex"`
 	Quoted        string  `json:"quote"`
 	NodeAddress   string  `json:"nodeAddress"`
+	Signature     string  `json:"signature,omitempty"` // Signature of the hash by the node
 	Timestamp     uint64  `json:"timestamp"`
 	Confirmations int     `json:"confirmations"`
 }
 
 // FullSignedBlock is the message to send to the connected clients through websocket
 type FullSignedBlock struct {
-	Hash      string `json:"hash"`
-	Height    uint64 `json:"height"`
-	Timestamp uint64 `json:"timestamp"`
+	Hash        string `json:"hash"`
+	HashVersion uint8  `json:"hashVersion,omitempty"` // Scheme of the hash, one of the *HashVersion values
+	Height      uint64 `json:"height"`
+	Timestamp   uint64 `json:"timestamp"`
 
 	AveragePrice    float64  `json:"avgPrice"`
 	AverageVolume   float64  `json:"avgVolumen"`
 	Ticker          string   `json:"ticker"`
 	PreviousHash    string   `json:"previousHash"`
-	Address         string   `json:"address"`
+	MerkleRoot      string   `json:"merkleRoot,omitempty"` // Root of the Merkle tree of the hashes of the evidence
+	Address         string   `json:"address"`              // Public key of the node that signed the block
 	PreviousAddress string   `json:"previousAddress"`
+	NextAddress     string   `json:"nextAddress,omitempty"` // Key that signs the next blocks, when the key of the node is rotated
 	Memo            string   `json:"memo"`
 	Evidence        []Result `json:"evidence"`
+	Signature       string   `json:"signature,omitempty"` // Ed25519 signature of the hash, by the owner of the address
 }
 
-// CreateHash calculates the hash for a block
+// CreateHash calculates the hash for a block, with the current hash scheme
 func (block *FullSignedBlock) CreateHash() error {
 
-	// create a hash the result
-	block.Hash = "" // To asure a clean hash
-	hash, err := calculateHash(block)
+	block.HashVersion = CurrentHashVersion
+	hash, err := block.ComputeHash()
 	if err == nil {
 		block.Hash = hash
 	}
 
-	// The hashes for the block has attached a prefix and the the number of seconds taken from the timestamp
-	seconds := time.Unix(int64(block.Timestamp), 0).Second()
-	block.Hash = fmt.Sprintf("%s%02d%s", BlockHashPrefix, seconds, block.Hash)
-
-	return err // No error
+	return err
 }
 
 // Implement the Stringer interface
@@ -116,7 +163,6 @@ func (block FullSignedBlock) String() string {
 
 // GetDataJob is the job message to insert in a queue to be processed as part of the the Mapping Stage
 type GetDataJob struct {
-	Quote       string
 	DataCrawler PriceEvidenceCrawler
 }
 
@@ -128,26 +174,58 @@ type Result struct {
 	Timestamp   int64          `json:"timestamp"`
 	Ticker      string         `json:"ticker"`
 	Hash        string         `json:"hash"`
+	HashVersion uint8          `json:"hashVersion,omitempty"` // Scheme of the hash, one of the *HashVersion values
+
+	Error           string `json:"error,omitempty"`
+	Excluded        bool   `json:"excluded,omitempty"`
+	ExclusionReason string `json:"exclusionReason,omitempty"`
+	// ConversionRate is applied to the prices of the source to quote them in the currency of the index
+	ConversionRate float64 `json:"conversionRate,omitempty"`
 }
 
-// CreateHash creates a double hash (sha256(sha256)) for all the content
+// CreateHash calculates the hash for all the content, with the current hash scheme
 func (result *Result) CreateHash() error {
-	// create a hash the result
-	result.Hash = "" // To asure a clean hash
-	hash, err := calculateHash(result)
 
+	result.HashVersion = CurrentHashVersion
+	hash, err := result.ComputeHash()
 	if err == nil {
 		result.Hash = hash
 	}
 
-	return err // No error
+	return err
+}
+
+// Exclude marks the result as not used to calculate the index, and updates the hash to include the reason
+func (result *Result) Exclude(reason string) error {
+	result.Excluded = true
+	result.ExclusionReason = reason
+
+	return result.CreateHash()
+}
+
+// Convert sets the rate to quote the prices of the result in the currency of the index, and updates the hash
+func (result *Result) Convert(rate float64) error {
+	result.ConversionRate = rate
+
+	return result.CreateHash()
+}
+
+// Price returns the latest price of the source, converted to the currency of the index if needed
+func (result Result) Price() float64 {
+	if result.ConversionRate == 0 {
+		return result.Data.LastPrice
+	}
+
+	return result.Data.LastPrice * result.ConversionRate
 }
 
-// PriceEvidenceCrawler is the interface for clients
+// PriceEvidenceCrawler is the interface for clients. Each crawler gets the data of a market from a venue.
+// Crawl must return when the context is done, with an error if the data could not be obtained
 type PriceEvidenceCrawler interface {
-	Crawl(quotedCurrency string, done chan QuotePriceInfo)
+	Crawl(ctx context.Context) (QuotePriceInfo, error)
 	GetName() string
-	GetTicker() string
+	GetTicker() string // The code of the market in the venue
+	GetMarket() Market
 }
 
 // Generate a hash using a double operation over the serialized content of object
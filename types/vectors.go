@@ -0,0 +1,237 @@
+/**
+ ** Copyright 2019 by Cratos Network, a project from Aquarelle AI
+**/
+package types
+
+import (
+	"crypto/ed25519"
+	"encoding/hex"
+)
+
+// vectorSeed is the seed of the Ed25519 key that signs the blocks of the test vectors. It must never be used
+// by a node
+var vectorSeed = []byte{
+	0x00, 0x01, 0x02, 0x03, 0x04, 0x05, 0x06, 0x07, 0x08, 0x09, 0x0a, 0x0b, 0x0c, 0x0d, 0x0e, 0x0f,
+	0x10, 0x11, 0x12, 0x13, 0x14, 0x15, 0x16, 0x17, 0x18, 0x19, 0x1a, 0x1b, 0x1c, 0x1d, 0x1e, 0x1f,
+}
+
+// Signs the test vectors with the vectorSeed key
+type vectorSigner struct {
+	privateKey ed25519.PrivateKey
+}
+
+func (s vectorSigner) Address() string {
+	return hex.EncodeToString(s.privateKey.Public().(ed25519.PublicKey))
+}
+
+func (s vectorSigner) SignHash(hash []byte) ([]byte, error) {
+	return ed25519.Sign(s.privateKey, hash), nil
+}
+
+// FixedPointVector is a test vector of the fixed-point numbers of the canonical encoding
+type FixedPointVector struct {
+	Value float64 `json:"value"`
+	Fixed string  `json:"fixed"` // Integer in base 10
+}
+
+// ResultVector is a test vector of the canonical hash of a source
+type ResultVector struct {
+	Result   Result `json:"result"`
+	Encoding string `json:"encoding"` // Hexadecimal
+	Hash     string `json:"hash"`
+}
+
+// BlockVector is a test vector of the canonical hash of a block, with its evidence
+type BlockVector struct {
+	Block    FullSignedBlock `json:"block"`
+	Encoding string          `json:"encoding"` // Hexadecimal
+	Hash     string          `json:"hash"`
+}
+
+// VersionVectors are the test vectors of the sources and the blocks hashed with a version of the scheme
+type VersionVectors struct {
+	HashVersion int            `json:"hashVersion"`
+	Results     []ResultVector `json:"results"`
+	Blocks      []BlockVector  `json:"blocks"`
+}
+
+// HashVectors are the published test vectors of the canonical hash scheme, so other implementations can check
+// their results. They are written to docs/hash-vectors.json, with the vectors of each canonical version, so the
+// chains hashed with the older versions can still be checked
+type HashVectors struct {
+	SignerSeed string             `json:"signerSeed"` // Seed of the Ed25519 key that signs the blocks, in hexadecimal
+	FixedPoint []FixedPointVector `json:"fixedPoint"`
+	Versions   []VersionVectors   `json:"versions"`
+}
+
+// Sources used in the test vectors
+func vectorResults() []Result {
+	return []Result{
+		{
+			CrawlerName: "Binance REST API",
+			Ticker:      "BTCUSDT",
+			Timestamp:   1573257600,
+			Data: QuotePriceInfo{
+				QuoteVolume:   360085376.25,
+				Volume:        41202.5546875,
+				HighPrice:     8888,
+				LastPrice:     8750.12,
+				OpenPrice:     8746.91015625,
+				BidPrice:      8750.11,
+				AskPrice:      8750.13,
+				Timestamp:     1573257600,
+				DataURL:       "https://api.binance.com/api/v3/ticker/24hr?symbol=BTCUSDT",
+				QuoteCurrency: "USDT",
+			},
+			ConversionRate: 0.99985,
+		},
+		{
+			CrawlerName:     "Kraken REST API",
+			Ticker:          "XBTUSD",
+			Timestamp:       1573257600,
+			Excluded:        true,
+			ExclusionReason: ExclusionOutlierMAD,
+			Data: QuotePriceInfo{
+				QuoteVolume:   0.1,
+				Volume:        0.000000015,
+				LastPrice:     9100.000000005,
+				Timestamp:     1573257600,
+				QuoteCurrency: "USD",
+			},
+		},
+		{
+			CrawlerName: "UpBit REST API",
+			Ticker:      "KRW-BTC",
+			Timestamp:   1573257600,
+			HasError:    true,
+			Error:       "context deadline exceeded",
+		},
+	}
+}
+
+// Hashes and signs a block of the vectors with a version of the scheme, which can be older than the current one
+func signVectorBlock(block *FullSignedBlock, signer vectorSigner, version uint8) error {
+
+	block.Address = signer.Address()
+	block.HashVersion = version
+	hash, err := block.ComputeHash()
+	if err != nil {
+		return err
+	}
+	block.Hash = hash
+
+	raw, err := hex.DecodeString(hash)
+	if err != nil {
+		return err
+	}
+	signature, err := signer.SignHash(raw)
+	if err != nil {
+		return err
+	}
+	block.Signature = hex.EncodeToString(signature)
+
+	return nil
+}
+
+// Builds the vectors of the sources and the blocks of a version. The key rotation block is only in the versions
+// which hash the NextAddress
+func versionVectors(version uint8) (VersionVectors, error) {
+
+	vectors := VersionVectors{HashVersion: int(version)}
+	signer := vectorSigner{privateKey: ed25519.NewKeyFromSeed(vectorSeed)}
+	nextSeed := make([]byte, len(vectorSeed))
+	for i := range vectorSeed {
+		nextSeed[i] = vectorSeed[len(vectorSeed)-1-i]
+	}
+	nextSigner := vectorSigner{privateKey: ed25519.NewKeyFromSeed(nextSeed)}
+
+	results := vectorResults()
+	for i := range results {
+		results[i].HashVersion = version
+		hash, err := results[i].ComputeHash()
+		if err != nil {
+			return vectors, err
+		}
+		results[i].Hash = hash
+		encoding, err := results[i].CanonicalEncoding()
+		if err != nil {
+			return vectors, err
+		}
+		vectors.Results = append(vectors.Results, ResultVector{Result: results[i], Encoding: hex.EncodeToString(encoding), Hash: results[i].Hash})
+	}
+
+	genesis := FullSignedBlock{
+		Height:        0,
+		Timestamp:     1573257600,
+		Ticker:        "BTCUSD",
+		AveragePrice:  8750.12,
+		AverageVolume: 41202.5546875,
+		Evidence:      results,
+	}
+	next := FullSignedBlock{
+		Height:        1,
+		Timestamp:     1573257605,
+		Ticker:        "BTCUSD",
+		AveragePrice:  8750.5,
+		AverageVolume: 41203,
+		Memo:          "overrun",
+		Evidence:      results[:1],
+	}
+	// Rotates the key to the one of the reversed seed. It has no price, and the timestamp of the previous block
+	rotation := FullSignedBlock{
+		Height:      2,
+		Timestamp:   1573257605,
+		Ticker:      "BTCUSD",
+		NextAddress: nextSigner.Address(),
+		Memo:        RotationMemo,
+	}
+
+	blocks := []*FullSignedBlock{&genesis, &next}
+	if version >= KeyRotationHashVersion {
+		blocks = append(blocks, &rotation)
+	}
+
+	var previous *FullSignedBlock
+	for _, block := range blocks {
+		if previous != nil {
+			block.PreviousHash = previous.Hash
+			block.PreviousAddress = previous.Address
+		}
+		previous = block
+		block.MerkleRoot = EvidenceRoot(block.Evidence)
+		if err := signVectorBlock(block, signer, version); err != nil {
+			return vectors, err
+		}
+		encoding, err := block.CanonicalEncoding()
+		if err != nil {
+			return vectors, err
+		}
+		vectors.Blocks = append(vectors.Blocks, BlockVector{Block: *block, Encoding: hex.EncodeToString(encoding), Hash: block.Hash})
+	}
+
+	return vectors, nil
+}
+
+// CanonicalHashVectors builds the test vectors of the canonical hash scheme, from the first canonical version to
+// the current one
+func CanonicalHashVectors() (HashVectors, error) {
+
+	vectors := HashVectors{SignerSeed: hex.EncodeToString(vectorSeed)}
+	for _, value := range []float64{0, 1, 0.1, 8750.12345678, 0.000000005, 0.000000015, -0.000000005, -8750.5, 1234567890123.45} {
+		fixed, err := FixedPoint(value)
+		if err != nil {
+			return vectors, err
+		}
+		vectors.FixedPoint = append(vectors.FixedPoint, FixedPointVector{Value: value, Fixed: fixed.String()})
+	}
+
+	for version := uint8(CanonicalHashVersion); version <= CurrentHashVersion; version++ {
+		versioned, err := versionVectors(version)
+		if err != nil {
+			return vectors, err
+		}
+		vectors.Versions = append(vectors.Versions, versioned)
+	}
+
+	return vectors, nil
+}